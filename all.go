@@ -2,34 +2,75 @@ package flowmatic
 
 import (
 	"context"
+	"errors"
 )
 
 // All runs each task concurrently
 // and waits for them all to finish.
 // Each task receives a child context
 // which is canceled once one task returns an error or panics.
+// The cause of the cancellation,
+// as reported by context.Cause,
+// is the first error returned by a task,
+// or a *PanicError if a task panicked first.
 // All returns nil if all tasks succeed.
 // Otherwise,
-// All returns a multierror containing the errors encountered.
+// All returns a multierror containing the errors encountered,
+// each wrapped in a *TaskError,
+// in the order of the tasks.
+// Errors returned by tasks only because of that cancellation,
+// such as context.Canceled or the cause itself,
+// are left out.
 // If a task panics during execution,
-// a panic will be caught and rethrown in the parent Goroutine.
+// a *PanicError will be caught and rethrown in the parent Goroutine.
+// If a task calls runtime.Goexit,
+// the parent Goroutine calls runtime.Goexit once all tasks are finished.
 func All(ctx context.Context, tasks ...func(context.Context) error) error {
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
+	return AllWith(ctx, tasks)
+}
+
+// AllWith is like All, but takes a slice of tasks and Options.
+func AllWith(ctx context.Context, tasks []func(context.Context) error, opts ...Option) error {
+	cfg := newConfig(opts)
+	parent := ctx
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
 
-	return eachN(len(tasks), len(tasks), func(pos int) error {
+	return eachN(cfg.workers(len(tasks)), tasks, func(pos int) error {
+		returned := false
 		defer func() {
-			panicVal := recover()
-			if panicVal != nil {
-				cancel()
-				panic(panicVal)
+			if returned {
+				return
+			}
+			// Cancel siblings if the task panicked
+			pval := recover()
+			if pval == nil {
+				// The task called runtime.Goexit
+				cancel(nil)
+				return
 			}
+			pe := newPanicError(pos, tasks[pos], pval)
+			cancel(pe)
+			panic(recovered{pe})
 		}()
 		err := tasks[pos](ctx)
-		if err != nil {
-			cancel()
-			return err
+		returned = true
+		if err == nil {
+			return nil
 		}
-		return nil
-	})
+		if canceledBySibling(parent, ctx, err) {
+			return nil
+		}
+		cancel(err)
+		return err
+	}, cfg)
+}
+
+// canceledBySibling reports whether err is only a consequence
+// of ctx being canceled because another task failed.
+func canceledBySibling(parent, ctx context.Context, err error) bool {
+	if ctx.Err() == nil || parent.Err() != nil {
+		return false
+	}
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.Cause(ctx))
 }
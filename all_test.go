@@ -0,0 +1,66 @@
+package flowmatic_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/carlmjohnson/flowmatic"
+)
+
+func TestAll_cause(t *testing.T) {
+	a := errors.New("a")
+	err := flowmatic.All(context.Background(),
+		func(ctx context.Context) error {
+			return a
+		},
+		func(ctx context.Context) error {
+			<-ctx.Done()
+			if cause := context.Cause(ctx); cause != a {
+				t.Error(cause)
+			}
+			return ctx.Err()
+		},
+		func(ctx context.Context) error {
+			<-ctx.Done()
+			return context.Cause(ctx)
+		},
+	)
+	if !errors.Is(err, a) || err.Error() != "a" {
+		t.Fatal(err)
+	}
+}
+
+func TestAll_parentCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := flowmatic.All(ctx,
+		func(ctx context.Context) error {
+			return ctx.Err()
+		},
+	)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatal(err)
+	}
+}
+
+func TestAll_panicCause(t *testing.T) {
+	err := flowmatic.AllWith(context.Background(), []func(context.Context) error{
+		func(ctx context.Context) error {
+			panic("boom")
+		},
+		func(ctx context.Context) error {
+			<-ctx.Done()
+			var pe *flowmatic.PanicError
+			if cause := context.Cause(ctx); !errors.As(cause, &pe) ||
+				pe.Value != "boom" || pe.Index != 0 {
+				t.Error(cause)
+			}
+			return ctx.Err()
+		},
+	}, flowmatic.PanicsAsErrors())
+	var pe *flowmatic.PanicError
+	if !errors.As(err, &pe) || pe.Value != "boom" {
+		t.Fatal(err)
+	}
+}
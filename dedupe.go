@@ -0,0 +1,23 @@
+package flowmatic
+
+// Dedupe returns a ManageOption that makes ManageTasks skip any input
+// that has already been queued, run, or completed.
+// As an exception,
+// a manager may re-queue the input it was just given,
+// such as to retry it after an error.
+// See DedupeBy to dedupe by a key instead.
+func Dedupe[Input comparable]() ManageOption[Input] {
+	return DedupeBy(func(in Input) Input {
+		return in
+	})
+}
+
+// DedupeBy is like Dedupe,
+// but two inputs are the same if key returns the same Key for them.
+func DedupeBy[Input any, Key comparable](key func(Input) Key) ManageOption[Input] {
+	return func(cfg *manageConfig[Input]) {
+		cfg.key = func(in Input) any {
+			return key(in)
+		}
+	}
+}
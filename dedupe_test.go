@@ -0,0 +1,57 @@
+package flowmatic_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/carlmjohnson/flowmatic"
+)
+
+func TestDedupe(t *testing.T) {
+	links := map[string][]string{
+		"/":  {"/a", "/b", "/a"},
+		"/a": {"/", "/b", "/c"},
+		"/b": {"/c", "/a"},
+		"/c": {"/"},
+	}
+	runs := map[string]int{}
+	failed := false
+	flowmatic.ManageTasksWith(2,
+		func(u string) ([]string, error) {
+			if u == "/c" && !failed {
+				failed = true
+				return nil, errors.New("flaky")
+			}
+			return links[u], nil
+		},
+		func(u string, urls []string, err error) ([]string, bool) {
+			runs[u]++
+			if err != nil {
+				// Retrying the current input is allowed
+				return []string{u}, true
+			}
+			return urls, true
+		}, []string{"/", "/"}, flowmatic.Dedupe[string]())
+	if s := fmt.Sprint(runs); s != "map[/:1 /a:1 /b:1 /c:2]" {
+		t.Fatal(s)
+	}
+}
+
+func TestDedupeBy(t *testing.T) {
+	var order []string
+	flowmatic.ManageTasksWith(1,
+		func(s string) (string, error) { return s, nil },
+		func(in, _ string, _ error) ([]string, bool) {
+			order = append(order, in)
+			if in == "a" {
+				// "A" has the same key as "a", so it counts as a retry
+				return []string{"A", "b", "B"}, true
+			}
+			return nil, true
+		}, []string{"a"}, flowmatic.DedupeBy(strings.ToLower))
+	if s := fmt.Sprint(order); s != "[a A b]" {
+		t.Fatal(s)
+	}
+}
@@ -0,0 +1,83 @@
+package flowmatic
+
+import "time"
+
+// NotBefore returns a ManageOption that makes ManageTasks hold back
+// each queued input until the time returned by when for it.
+// A manager can use it to retry an input after a delay
+// by returning a copy of the input that records when to try again.
+// Inputs whose time is zero or has passed are queued right away.
+// ManageTasks keeps running until no inputs are held back.
+func NotBefore[Input any](when func(Input) time.Time) ManageOption[Input] {
+	return func(cfg *manageConfig[Input]) {
+		cfg.notBefore = when
+	}
+}
+
+// timed is an input along with the time it may run.
+type timed[Input any] struct {
+	at   time.Time
+	item Input
+}
+
+// delayQueue holds inputs until they may run.
+type delayQueue[Input any] struct {
+	pq    priorityQueue[timed[Input]]
+	timer *time.Timer
+}
+
+func newDelayQueue[Input any]() *delayQueue[Input] {
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	return &delayQueue[Input]{
+		pq: priorityQueue[timed[Input]]{
+			less: func(a, b timed[Input]) bool {
+				return a.at.Before(b.at)
+			},
+		},
+		timer: timer,
+	}
+}
+
+// Len returns the number of inputs held back.
+func (dq *delayQueue[Input]) Len() int {
+	return len(dq.pq.items)
+}
+
+// Push holds back item until at.
+func (dq *delayQueue[Input]) Push(at time.Time, item Input) {
+	dq.pq.Push(timed[Input]{at, item})
+}
+
+// PopDue removes and returns the next input whose time has passed.
+func (dq *delayQueue[Input]) PopDue(now time.Time) (item Input, ok bool) {
+	next, ok := dq.pq.Peek()
+	if !ok || next.at.After(now) {
+		return item, false
+	}
+	dq.pq.Pop()
+	return next.item, true
+}
+
+// Wait returns a channel that receives once the next input is due,
+// or nil if no inputs are held back.
+// Call Stop before calling Wait again.
+func (dq *delayQueue[Input]) Wait(now time.Time) <-chan time.Time {
+	next, ok := dq.pq.Peek()
+	if !ok {
+		return nil
+	}
+	dq.timer.Reset(next.at.Sub(now))
+	return dq.timer.C
+}
+
+// Stop stops the timer started by Wait if it has not fired.
+// fired reports whether its channel was received from.
+func (dq *delayQueue[Input]) Stop(fired bool) {
+	if !fired && !dq.timer.Stop() {
+		select {
+		case <-dq.timer.C:
+		default:
+		}
+	}
+}
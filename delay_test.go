@@ -0,0 +1,65 @@
+package flowmatic_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/flowmatic"
+)
+
+type job struct {
+	n  int
+	at time.Time
+}
+
+func jobTime(j job) time.Time { return j.at }
+
+func TestNotBefore(t *testing.T) {
+	const delay = 20 * time.Millisecond
+	start := time.Now()
+	var order []int
+	var ran time.Duration
+	flowmatic.ManageTasksWith(2,
+		func(j job) (int, error) { return j.n, nil },
+		func(j job, n int, err error) ([]job, bool) {
+			order = append(order, n)
+			switch n {
+			case 0:
+				return []job{
+					{3, time.Now().Add(2 * delay)},
+					{1, time.Now().Add(delay)},
+					{2, time.Time{}},
+				}, true
+			case 3:
+				ran = time.Since(start)
+			}
+			return nil, true
+		}, []job{{0, time.Time{}}}, flowmatic.NotBefore(jobTime))
+	if s := fmt.Sprint(order); s != "[0 2 1 3]" {
+		t.Fatal(s)
+	}
+	if ran < 2*delay {
+		t.Fatal(ran)
+	}
+}
+
+func TestNotBefore_canceled(t *testing.T) {
+	errStop := errors.New("stop")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	time.AfterFunc(10*time.Millisecond, func() { cancel(errStop) })
+	start := time.Now()
+	err := flowmatic.ManageTasksContextWith(ctx, 1,
+		func(_ context.Context, j job) (int, error) { return j.n, nil },
+		func(j job, n int, err error) ([]job, error) {
+			return []job{{n + 1, time.Now().Add(time.Hour)}}, nil
+		}, []job{{0, time.Time{}}}, flowmatic.NotBefore(jobTime))
+	if err != errStop {
+		t.Fatal(err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("waited for the delayed input")
+	}
+}
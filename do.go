@@ -2,35 +2,65 @@ package flowmatic
 
 import (
 	"errors"
+	"runtime"
 	"sync"
 )
 
 // Do runs each task concurrently
 // and waits for them all to finish.
 // Errors returned by tasks do not cancel execution,
-// but are joined into a multierror return value.
+// but are wrapped in a *TaskError and joined into a multierror return value
+// in the order of the tasks.
 // If a task panics during execution,
-// a panic will be caught and rethrown in the parent Goroutine.
+// a *PanicError will be caught and rethrown in the parent Goroutine.
+// If a task calls runtime.Goexit,
+// the parent Goroutine calls runtime.Goexit once all tasks are finished.
 func Do(tasks ...func() error) error {
+	return DoWith(tasks)
+}
+
+// DoWith is like Do, but takes a slice of tasks and Options.
+func DoWith(tasks []func() error, opts ...Option) error {
+	return doTasks(tasks, func(task func() error) error {
+		return task()
+	}, newConfig(opts))
+}
+
+// doTasks runs call on each task concurrently
+// with the semantics of Do.
+func doTasks[Task any](tasks []Task, call func(Task) error, cfg config) error {
 	type result struct {
-		err   error
-		panic any
+		index  int
+		err    error
+		panic  *PanicError
+		goexit bool
 	}
 
 	var wg sync.WaitGroup
 	errch := make(chan result, len(tasks))
+	sem := make(chan struct{}, cfg.workers(len(tasks)))
 
 	wg.Add(len(tasks))
 	for i := range tasks {
-		fn := tasks[i]
+		i, task := i, tasks[i]
+		sem <- struct{}{}
 		go func() {
 			defer wg.Done()
+			defer func() { <-sem }()
+			returned := false
 			defer func() {
+				if returned {
+					return
+				}
 				if panicVal := recover(); panicVal != nil {
-					errch <- result{panic: panicVal}
+					errch <- result{panic: newPanicError(i, task, panicVal)}
+					return
 				}
+				errch <- result{goexit: true}
 			}()
-			errch <- result{err: fn()}
+			err := call(task)
+			returned = true
+			errch <- result{index: i, err: wrapTaskError(i, task, err)}
 		}()
 	}
 	go func() {
@@ -39,21 +69,24 @@ func Do(tasks ...func() error) error {
 	}()
 
 	var (
-		panicVal any
-		errs     []error
+		panics PanicErrors
+		errs   = make([]error, len(tasks))
+		goexit bool
 	)
 	for res := range errch {
 		switch {
-		case res.err == nil && res.panic == nil:
-			continue
+		case res.goexit:
+			goexit = true
 		case res.panic != nil:
-			panicVal = res.panic
+			i := res.panic.Index
+			errs[i] = wrapTaskError(i, tasks[i], cfg.handlePanic(res.panic, &panics))
 		case res.err != nil:
-			errs = append(errs, res.err)
+			errs[res.index] = res.err
 		}
 	}
-	if panicVal != nil {
-		panic(panicVal)
+	panics.rethrow()
+	if goexit {
+		runtime.Goexit()
 	}
 	return errors.Join(errs...)
 }
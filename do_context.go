@@ -0,0 +1,37 @@
+package flowmatic
+
+import (
+	"context"
+	"errors"
+)
+
+// DoContext runs each task concurrently
+// and waits for them all to finish.
+// Each task receives a child context
+// which is canceled only if the parent context is canceled.
+// Errors returned by tasks do not cancel execution,
+// but are wrapped in a *TaskError and joined into a multierror return value
+// in the order of the tasks.
+// If the parent context was canceled,
+// the return value also includes context.Cause of the parent.
+// If a task panics during execution,
+// a *PanicError will be caught and rethrown in the parent Goroutine.
+// If a task calls runtime.Goexit,
+// the parent Goroutine calls runtime.Goexit once all tasks are finished.
+func DoContext(ctx context.Context, tasks ...func(context.Context) error) error {
+	return DoContextWith(ctx, tasks)
+}
+
+// DoContextWith is like DoContext, but takes a slice of tasks and Options.
+func DoContextWith(ctx context.Context, tasks []func(context.Context) error, opts ...Option) error {
+	childctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	err := doTasks(tasks, func(task func(context.Context) error) error {
+		return task(childctx)
+	}, newConfig(opts))
+	if cause := context.Cause(ctx); cause != nil && !errors.Is(err, cause) {
+		err = errors.Join(err, cause)
+	}
+	return err
+}
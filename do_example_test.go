@@ -1,6 +1,8 @@
 package flowmatic_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -33,3 +35,25 @@ func ExampleDo() {
 	// from flowmatic.Do
 	// executed concurrently? true
 }
+
+func ExampleDoContext() {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := flowmatic.DoContext(ctx,
+		func(ctx context.Context) error {
+			// An error here does not cancel the other task
+			return errors.New("oops")
+		},
+		func(ctx context.Context) error {
+			// But the parent's timeout does
+			if !sleepFor(ctx, 1*time.Minute) {
+				fmt.Println("canceled")
+			}
+			return nil
+		})
+	fmt.Println("err:", err)
+	// Output:
+	// canceled
+	// err: oops
+	// context deadline exceeded
+}
@@ -1,8 +1,12 @@
 package flowmatic_test
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/carlmjohnson/flowmatic"
 )
@@ -21,3 +25,141 @@ func TestDo_err(t *testing.T) {
 		t.Fatal(errs)
 	}
 }
+
+func TestDoContext(t *testing.T) {
+	a := errors.New("a")
+	cause := errors.New("cause")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	errs := flowmatic.DoContext(ctx,
+		func(context.Context) error {
+			return a
+		},
+		func(ctx context.Context) error {
+			if !sleepFor(ctx, 10*time.Millisecond) {
+				t.Error("task error should not cancel siblings")
+			}
+			cancel(cause)
+			return nil
+		},
+		func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		},
+	)
+	if !errors.Is(errs, a) {
+		t.Fatal(errs)
+	}
+	if !errors.Is(errs, cause) {
+		t.Fatal(errs)
+	}
+}
+
+// limitTracker records the maximum number of tasks running at once.
+type limitTracker struct {
+	cur, max atomic.Int64
+}
+
+func (lt *limitTracker) run() {
+	lt.start()
+	defer lt.done()
+	time.Sleep(time.Millisecond)
+}
+
+// start records a task starting and returns how many are running.
+func (lt *limitTracker) start() int64 {
+	n := lt.cur.Add(1)
+	for {
+		m := lt.max.Load()
+		if n <= m || lt.max.CompareAndSwap(m, n) {
+			return n
+		}
+	}
+}
+
+// done records a task finishing.
+func (lt *limitTracker) done() {
+	lt.cur.Add(-1)
+}
+
+func TestWorkers(t *testing.T) {
+	const numTasks, numWorkers = 20, 3
+	ctx := context.Background()
+	var lt limitTracker
+	tasks := make([]func() error, numTasks)
+	ctxTasks := make([]func(context.Context) error, numTasks)
+	for i := range tasks {
+		tasks[i] = func() error {
+			lt.run()
+			return nil
+		}
+		ctxTasks[i] = func(context.Context) error {
+			lt.run()
+			return errors.New("keep racing")
+		}
+	}
+	cases := map[string]func(){
+		"Do": func() {
+			_ = flowmatic.DoWith(tasks, flowmatic.Workers(numWorkers))
+		},
+		"DoContext": func() {
+			_ = flowmatic.DoContextWith(ctx, ctxTasks, flowmatic.Workers(numWorkers))
+		},
+		"All": func() {
+			_ = flowmatic.AllWith(ctx, ctxTasks, flowmatic.Workers(numWorkers))
+		},
+		"Race": func() {
+			_ = flowmatic.RaceWith(ctx, ctxTasks, flowmatic.Workers(numWorkers))
+		},
+	}
+	for name, f := range cases {
+		t.Run(name, func(t *testing.T) {
+			lt.max.Store(0)
+			f()
+			if m := lt.max.Load(); m > numWorkers || m < 1 {
+				t.Fatal(m)
+			}
+		})
+	}
+}
+
+func TestErrorOrder(t *testing.T) {
+	// Tasks that finish in reverse order
+	fail := func(i int) error {
+		time.Sleep(time.Duration(3-i) * 5 * time.Millisecond)
+		return fmt.Errorf("%d", i)
+	}
+	ctx := context.Background()
+	cases := map[string]func() error{
+		"Do": func() error {
+			return flowmatic.Do(
+				func() error { return fail(0) },
+				func() error { return fail(1) },
+				func() error { return fail(2) },
+			)
+		},
+		"Each": func() error {
+			return flowmatic.Each(3, []int{0, 1, 2}, fail)
+		},
+		"All": func() error {
+			return flowmatic.All(ctx,
+				func(context.Context) error { return fail(0) },
+				func(context.Context) error { return fail(1) },
+				func(context.Context) error { return fail(2) },
+			)
+		},
+		"Race": func() error {
+			return flowmatic.Race(ctx,
+				func(context.Context) error { return fail(0) },
+				func(context.Context) error { return fail(1) },
+				func(context.Context) error { return fail(2) },
+			)
+		},
+	}
+	for name, f := range cases {
+		t.Run(name, func(t *testing.T) {
+			if err := f(); err == nil || err.Error() != "0\n1\n2" {
+				t.Fatalf("%q", err)
+			}
+		})
+	}
+}
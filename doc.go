@@ -2,14 +2,35 @@
 //
 // Comparison of simple helpers:
 //
-//	       Tasks       Cancels Context?   Collect results?
-//	Do     Different   No                 No
-//	All    Different   On error           No
-//	Race   Different   On success         No
-//	Each   Same        No                 No
-//	Map    Same        On error           Yes
+//	            Tasks       Cancels Context?   Collect results?
+//	Do          Different   No                 No
+//	DoContext   Different   No                 No
+//	All         Different   On error           No
+//	Race        Different   On success         No
+//	Each        Same        No                 No
+//	EachContext Same        On error           No
+//	EachSeq     Same        No                 No
+//	Map         Same        On error           Yes
+//	MapSettled  Same        No                 Yes
+//	MapSeq      Same        On break           Yes
 //
-// ManageTasks and TaskPool allow for advanced concurrency patterns.
+// ManageTasks, ManageTasksContext, and TaskPool allow for advanced concurrency patterns.
+// TaskPoolState, EachState, and MapState give each worker its own state,
+// such as a connection or a buffer.
+// RetryContext, RetryMap, and RetryTask wrap tasks
+// to retry them with exponential backoff.
+//
+// By default, a panic in a task is caught and rethrown in the parent Goroutine
+// as a *PanicError.
+// Helpers accept Options, such as PanicsAsErrors,
+// to handle panics differently.
+// Helpers whose final argument is variadic
+// have a With variant that takes a slice and Options instead.
+// ManageTasksWith and ManageTasksContextWith take ManageOptions,
+// which are specific to their Input type;
+// PoolOptions turns Options into ManageOptions.
+// Pass a Limiter, such as DynamicLimit or AIMDLimit, to the WorkerLimit Option
+// to resize a pool of workers while it runs.
 package flowmatic
 
 // MaxProcs means use GOMAXPROCS workers when doing tasks.
@@ -1,39 +1,73 @@
 package flowmatic
 
 import (
+	"context"
 	"errors"
+	"runtime"
 )
 
 // Each starts numWorkers concurrent workers (or GOMAXPROCS workers if numWorkers < 1)
 // and processes each item as a task.
 // Errors returned by a task do not halt execution,
-// but are joined into a multierror return value.
+// but are wrapped in a *TaskError and joined into a multierror return value
+// in the order of the items.
 // If a task panics during execution,
-// the panic will be caught and rethrown in the parent Goroutine.
-func Each[Input any](numWorkers int, items []Input, task func(Input) error) error {
-	return eachN(numWorkers, len(items), func(pos int) error {
+// a *PanicError will be caught and rethrown in the parent Goroutine.
+// If a task calls runtime.Goexit,
+// the parent Goroutine calls runtime.Goexit once all tasks are finished.
+func Each[Input any](numWorkers int, items []Input, task func(Input) error, opts ...Option) error {
+	return eachN(numWorkers, items, func(pos int) error {
 		return task(items[pos])
-	})
+	}, newConfig(opts))
 }
 
+// EachContext starts numWorkers concurrent workers (or GOMAXPROCS workers if numWorkers < 1)
+// and processes each item as a task.
+// Each task receives a child context.
+// The first error or panic returned by a task
+// cancels the child context
+// and halts further task scheduling.
+// EachContext returns the first error wrapped in a *TaskError.
+// If a task panics during execution,
+// a *PanicError will be caught and rethrown in the parent Goroutine.
+// If a task calls runtime.Goexit,
+// EachContext halts as for a panic
+// and then calls runtime.Goexit in the parent Goroutine.
+// It is like Map, but without collecting results.
+func EachContext[Input any](ctx context.Context, numWorkers int, items []Input, task func(context.Context, Input) error, opts ...Option) error {
+	_, err := Map(ctx, numWorkers, items, func(ctx context.Context, in Input) (void, error) {
+		return void{}, task(ctx, in)
+	}, opts...)
+	return err
+}
+
+// void is the Output of tasks that only return an error.
+type void struct{}
+
 // eachN starts numWorkers concurrent workers (or GOMAXPROCS workers if numWorkers < 1)
-// and starts a task for each number from 0 to numItems.
+// and starts a task for each position in items.
 // Errors returned by a task do not halt execution,
-// but are joined into a multierror return value.
+// but are wrapped in a *TaskError and joined into a multierror return value
+// in the order of the items.
 // If a task panics during execution,
-// the panic will be caught and rethrown in the parent Goroutine.
-func eachN(numWorkers, numItems int, task func(int) error) error {
-	type void struct{}
-	inch, ouch := TaskPool(numWorkers, func(pos int) (void, error) {
+// a *PanicError will be caught and rethrown in the parent Goroutine.
+func eachN[Item any](numWorkers int, items []Item, task func(int) error, cfg config) error {
+	return eachWorker(numWorkers, items, stateless(func(pos int) (void, error) {
 		return void{}, task(pos)
-	})
+	}), cfg)
+}
+
+// eachWorker is like eachN, but each worker gets its task from start.
+func eachWorker[Item any](numWorkers int, items []Item, start starter[int, void], cfg config) error {
+	inch, ouch := newTaskPool(numWorkers, start, cfg.poolConfig(), nil)
 	var (
-		panicVal any
-		errs     []error
+		panics PanicErrors
+		errs   = make([]error, len(items))
+		goexit bool
 	)
 	_ = Do(
 		func() error {
-			for i := 0; i < numItems; i++ {
+			for i := range items {
 				inch <- i
 			}
 			close(inch)
@@ -41,17 +75,24 @@ func eachN(numWorkers, numItems int, task func(int) error) error {
 		},
 		func() error {
 			for r := range ouch {
-				if r.Panic != nil && panicVal == nil {
-					panicVal = r.Panic
+				if r.Goexit {
+					goexit = true
 				}
-				if r.Err != nil {
-					errs = append(errs, r.Err)
+				if r.Panic != nil {
+					pe := r.Panic.(*PanicError)
+					if pe.Index < 0 {
+						pe.Index = r.In
+						pe.Input = items[r.In]
+					}
+					r.Err = cfg.handlePanic(pe, &panics)
 				}
+				errs[r.In] = wrapTaskError(r.In, items[r.In], r.Err)
 			}
 			return nil
 		})
-	if panicVal != nil {
-		panic(panicVal)
+	panics.rethrow()
+	if goexit {
+		runtime.Goexit()
 	}
 	return errors.Join(errs...)
 }
@@ -1,6 +1,7 @@
 package flowmatic_test
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -27,3 +28,23 @@ func TestEach_err(t *testing.T) {
 		t.Fatal(errs)
 	}
 }
+
+func TestEachContext(t *testing.T) {
+	ctx := context.Background()
+	a := errors.New("a")
+	b := errors.New("b")
+	err := flowmatic.EachContext(ctx, 1, []int{1, 2, 3}, func(ctx context.Context, i int) error {
+		switch i {
+		case 1:
+			return a
+		case 2:
+			return b
+		default:
+			panic("should be halted by now!")
+		}
+	})
+	var te *flowmatic.TaskError[int]
+	if !errors.As(err, &te) || te.Err != a || te.Index != 0 || te.Input != 1 {
+		t.Fatal(err)
+	}
+}
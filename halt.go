@@ -0,0 +1,45 @@
+package flowmatic
+
+// Unprocessed describes the work left over when ManageTasks halts early.
+type Unprocessed[Input any] struct {
+	// Queued holds the inputs that were queued,
+	// or held back by NotBefore,
+	// but never started.
+	Queued []Input
+	// InFlight holds the inputs that were running when ManageTasks halted.
+	// Their results were not passed to the manager.
+	InFlight []Input
+}
+
+// OnHalt returns a ManageOption that makes ManageTasks call fn
+// with the inputs left unprocessed
+// when it halts before running out of work,
+// so that the work can be resumed later.
+// ManageTasks halts early when the manager halts,
+// a task panics or calls runtime.Goexit,
+// or the context of ManageTasksContext is canceled.
+// Fn is called once running tasks have returned.
+// Queued inputs are removed from any Queue set by UseQueue.
+func OnHalt[Input any](fn func(Unprocessed[Input])) ManageOption[Input] {
+	return func(cfg *manageConfig[Input]) {
+		cfg.onHalt = fn
+	}
+}
+
+// unqueue empties queue and then delayed
+// and returns their inputs in the order they would have run.
+func unqueue[Input any](queue Queue[Input], delayed *delayQueue[Input]) []Input {
+	var items []Input
+	for {
+		item, ok := queue.Pop()
+		if !ok {
+			break
+		}
+		items = append(items, item)
+	}
+	for delayed.Len() > 0 {
+		next, _ := delayed.pq.Pop()
+		items = append(items, next.item)
+	}
+	return items
+}
@@ -0,0 +1,110 @@
+package flowmatic_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/flowmatic"
+)
+
+func TestOnHalt(t *testing.T) {
+	started := make(chan struct{})
+	halted := make(chan struct{})
+	var left flowmatic.Unprocessed[int]
+	calls := 0
+	flowmatic.ManageTasksWith(2,
+		func(n int) (int, error) {
+			switch n {
+			case 0:
+				// Halt once 1 is running
+				<-started
+			case 1:
+				close(started)
+				<-halted
+			}
+			return n, nil
+		},
+		func(in, out int, err error) ([]int, bool) {
+			if in == 0 {
+				close(halted)
+				return nil, false
+			}
+			return nil, true
+		}, []int{0, 1, 2, 3},
+		flowmatic.OnHalt(func(u flowmatic.Unprocessed[int]) {
+			calls++
+			left = u
+		}))
+	// 2 may or may not have started before the halt
+	all := append(slices.Clone(left.Queued), left.InFlight...)
+	slices.Sort(all)
+	if s := fmt.Sprint(all); calls != 1 || s != "[1 2 3]" || !slices.Contains(left.InFlight, 1) {
+		t.Fatal(calls, left)
+	}
+}
+
+func TestOnHalt_finished(t *testing.T) {
+	called := false
+	flowmatic.ManageTasksWith(2,
+		func(n int) (int, error) { return n, nil },
+		func(in, out int, err error) ([]int, bool) { return nil, true },
+		[]int{0, 1, 2},
+		flowmatic.OnHalt(func(flowmatic.Unprocessed[int]) { called = true }))
+	if called {
+		t.Fatal("called without halting")
+	}
+}
+
+func TestOnHalt_delayed(t *testing.T) {
+	errStop := errors.New("stop")
+	var left flowmatic.Unprocessed[job]
+	err := flowmatic.ManageTasksContextWith(context.Background(), 1,
+		func(_ context.Context, j job) (int, error) { return j.n, nil },
+		func(j job, n int, err error) ([]job, error) {
+			if n == 0 {
+				return []job{
+					{2, time.Now().Add(time.Hour)},
+					{1, time.Time{}},
+				}, nil
+			}
+			return nil, errStop
+		}, []job{{0, time.Time{}}},
+		flowmatic.NotBefore(jobTime),
+		flowmatic.OnHalt(func(u flowmatic.Unprocessed[job]) {
+			left = u
+		}))
+	if err != errStop {
+		t.Fatal(err)
+	}
+	if len(left.Queued) != 1 || left.Queued[0].n != 2 || len(left.InFlight) != 0 {
+		t.Fatal(left)
+	}
+}
+
+func TestOnHalt_poolOptions(t *testing.T) {
+	var ran []int
+	var left flowmatic.Unprocessed[int]
+	flowmatic.ManageTasksWith(1,
+		func(n int) (int, error) { return n, nil },
+		func(in, out int, err error) ([]int, bool) {
+			ran = append(ran, in)
+			return nil, false
+		}, []int{0, 1, 2, 3, 4, 5},
+		// Options for TaskPool's own buffering don't apply
+		flowmatic.PoolOptions[int](
+			flowmatic.QueueSize(10),
+			flowmatic.OnOverflow(flowmatic.Reject),
+			flowmatic.OutputBuffer(10),
+		),
+		flowmatic.OnHalt(func(u flowmatic.Unprocessed[int]) {
+			left = u
+		}))
+	if len(ran) != 1 || len(left.InFlight) > 1 ||
+		len(left.Queued)+len(left.InFlight) != 5 {
+		t.Fatal(ran, left)
+	}
+}
@@ -0,0 +1,204 @@
+package flowmatic
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Limiter decides how many workers a pool may use.
+// Pass it to a helper with the WorkerLimit Option.
+// Pools call Observe with the latency and error of each task they run
+// and check Limit before each task.
+// Surplus workers exit after finishing their current task,
+// and new workers are started after a task is observed.
+// A pool always keeps at least one worker,
+// so a Limit less than 1 is treated as 1.
+// Limiters must be safe for concurrent use.
+//
+// A Limiter whose limit can change between tasks,
+// such as by a timer or by hand,
+// should also implement LimitNotifier.
+// Otherwise, idle workers only notice a lower limit
+// after they have taken one more task,
+// and new workers only start after a task is observed.
+type Limiter interface {
+	Limit() int
+	Observe(latency time.Duration, err error)
+}
+
+// LimitNotifier is an optional interface for a Limiter.
+// Changed returns a channel that is closed the next time Limit changes.
+// Pools wait on it to start or retire idle workers right away.
+type LimitNotifier interface {
+	Changed() <-chan struct{}
+}
+
+// workerLimit returns the number of workers l allows,
+// which is at least 1.
+func workerLimit(l Limiter) int {
+	return max(l.Limit(), 1)
+}
+
+// limitChanged returns a channel that is closed the next time l changes,
+// or nil if l cannot report its changes.
+func limitChanged(l Limiter) <-chan struct{} {
+	if n, ok := l.(LimitNotifier); ok {
+		return n.Changed()
+	}
+	return nil
+}
+
+// DynamicLimit is a Limiter for changing the number of workers
+// used by a running pool by hand.
+// A DynamicLimit may be shared by more than one pool,
+// in which case each pool uses that many workers.
+// The zero value is a limit of one worker.
+type DynamicLimit struct {
+	mu   sync.Mutex
+	n    int
+	wake chan struct{}
+}
+
+// NewDynamicLimit returns a DynamicLimit of n workers (or GOMAXPROCS workers if n < 1).
+func NewDynamicLimit(n int) *DynamicLimit {
+	if n < 1 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	return &DynamicLimit{n: n}
+}
+
+// Resize sets the limit to n workers (or GOMAXPROCS workers if n < 1).
+// If the limit grows, pools start new workers right away.
+// If it shrinks, surplus workers exit after finishing their current task.
+func (dl *DynamicLimit) Resize(n int) {
+	if n < 1 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	dl.n = n
+	if dl.wake != nil {
+		close(dl.wake)
+		dl.wake = nil
+	}
+}
+
+// Limit returns the current number of workers.
+func (dl *DynamicLimit) Limit() int {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	return max(dl.n, 1)
+}
+
+// Observe implements Limiter. It does nothing.
+func (dl *DynamicLimit) Observe(time.Duration, error) {}
+
+// Changed implements LimitNotifier.
+func (dl *DynamicLimit) Changed() <-chan struct{} {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	if dl.wake == nil {
+		dl.wake = make(chan struct{})
+	}
+	return dl.wake
+}
+
+// AIMDLimit is a Limiter that adjusts the number of workers
+// to the latency and errors of tasks
+// with additive increase and multiplicative decrease.
+// After a full limit's worth of tasks succeed
+// without a rise in latency,
+// the limit grows by one.
+// When a task returns an error that signals overload or panics,
+// or its latency is more than twice the recent average,
+// the limit shrinks by 10%.
+// The zero value starts at one worker and has no maximum.
+type AIMDLimit struct {
+	// Overloaded reports whether a task that returned err
+	// was failed by overload, such as an HTTP 429 response.
+	// Other errors count as successes.
+	// A panic always counts as overload.
+	// If Overloaded is nil, every error signals overload.
+	// Set it before the AIMDLimit is used.
+	Overloaded func(err error) bool
+
+	dl       DynamicLimit
+	mu       sync.Mutex
+	min, max int
+	avg      time.Duration
+	passed   int
+}
+
+// NewAIMDLimit returns an AIMDLimit that starts at initial workers
+// and stays between minWorkers and maxWorkers.
+// If minWorkers < 1, the minimum is 1.
+// If maxWorkers < 1, the maximum is unbounded.
+func NewAIMDLimit(initial, minWorkers, maxWorkers int) *AIMDLimit {
+	al := &AIMDLimit{
+		min: minWorkers,
+		max: maxWorkers,
+	}
+	lo, hi := al.bounds()
+	al.dl.n = clamp(initial, lo, hi)
+	return al
+}
+
+// bounds returns the minimum and maximum limits.
+func (al *AIMDLimit) bounds() (lo, hi int) {
+	lo, hi = max(al.min, 1), al.max
+	if hi < 1 {
+		hi = int(^uint(0) >> 1)
+	}
+	return lo, max(lo, hi)
+}
+
+// Limit returns the current number of workers.
+func (al *AIMDLimit) Limit() int {
+	return al.dl.Limit()
+}
+
+// Observe adjusts the limit after a task finishes.
+func (al *AIMDLimit) Observe(latency time.Duration, err error) {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	limit := al.dl.Limit()
+	lo, hi := al.bounds()
+	var pe *PanicError
+	if err != nil && al.Overloaded != nil &&
+		!errors.As(err, &pe) && !al.Overloaded(err) {
+		err = nil
+	}
+	slow := al.avg > 0 && latency > 2*al.avg
+	if err == nil {
+		if al.avg == 0 {
+			al.avg = latency
+		} else {
+			// Average over roughly the last ten tasks
+			al.avg += (latency - al.avg) / 10
+		}
+	}
+	if err != nil || slow {
+		al.passed = 0
+		next := clamp(min(limit*9/10, limit-1), lo, hi)
+		if next != limit {
+			al.dl.Resize(next)
+		}
+		return
+	}
+	al.passed++
+	if al.passed >= limit && limit < hi {
+		al.passed = 0
+		al.dl.Resize(limit + 1)
+	}
+}
+
+// Changed implements LimitNotifier.
+func (al *AIMDLimit) Changed() <-chan struct{} {
+	return al.dl.Changed()
+}
+
+func clamp(n, lo, hi int) int {
+	return max(lo, min(n, hi))
+}
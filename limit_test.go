@@ -0,0 +1,218 @@
+package flowmatic_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/flowmatic"
+)
+
+func TestAIMDLimit(t *testing.T) {
+	al := flowmatic.NewAIMDLimit(4, 2, 6)
+	check := func(want int) {
+		t.Helper()
+		if got := al.Limit(); got != want {
+			t.Fatalf("want %d; got %d", want, got)
+		}
+	}
+	check(4)
+	// A full limit of successes adds a worker
+	for i := 0; i < 4; i++ {
+		al.Observe(time.Millisecond, nil)
+	}
+	check(5)
+	// Errors shrink it
+	al.Observe(time.Millisecond, errors.New("429"))
+	check(4)
+	// So does a jump in latency
+	al.Observe(10*time.Millisecond, nil)
+	check(3)
+	al.Observe(time.Millisecond, errors.New("429"))
+	al.Observe(time.Millisecond, errors.New("429"))
+	check(2)
+	for i := 0; i < 100; i++ {
+		al.Observe(time.Millisecond, nil)
+	}
+	check(6)
+}
+
+func TestAIMDLimit_Overloaded(t *testing.T) {
+	errBusy := errors.New("429")
+	al := flowmatic.NewAIMDLimit(4, 1, 10)
+	al.Overloaded = func(err error) bool {
+		return errors.Is(err, errBusy)
+	}
+	// Ordinary failures count as successes
+	for i := 0; i < 4; i++ {
+		al.Observe(time.Millisecond, errors.New("404"))
+	}
+	if l := al.Limit(); l != 5 {
+		t.Fatal(l)
+	}
+	al.Observe(time.Millisecond, fmt.Errorf("fetching: %w", errBusy))
+	if l := al.Limit(); l != 4 {
+		t.Fatal(l)
+	}
+}
+
+func TestAIMDLimit_zero(t *testing.T) {
+	al := &flowmatic.AIMDLimit{
+		Overloaded: func(error) bool { return false },
+	}
+	if l := al.Limit(); l != 1 {
+		t.Fatal(l)
+	}
+	for i := 0; i < 3; i++ {
+		al.Observe(time.Millisecond, errors.New("404"))
+	}
+	if l := al.Limit(); l != 3 {
+		t.Fatal(l)
+	}
+	// Panics count as overload even if Overloaded says otherwise
+	al.Observe(time.Millisecond, &flowmatic.PanicError{Value: "boom"})
+	if l := al.Limit(); l != 2 {
+		t.Fatal(l)
+	}
+}
+
+func TestAIMDLimit_pool(t *testing.T) {
+	al := flowmatic.NewAIMDLimit(1, 1, 8)
+	var lt limitTracker
+	err := flowmatic.Each(0, make([]int, 500), func(int) error {
+		n := lt.start()
+		defer lt.done()
+		// An overloaded server
+		if n > 4 {
+			return errors.New("429")
+		}
+		time.Sleep(100 * time.Microsecond)
+		return nil
+	}, flowmatic.WorkerLimit(al))
+	if err == nil {
+		t.Fatal("never overloaded")
+	}
+	if p := lt.max.Load(); p < 2 || p > 8 {
+		t.Fatal(p)
+	}
+	if l := al.Limit(); l > 8 {
+		t.Fatal(l)
+	}
+}
+
+// pausedLimit is a Limiter that asks for no workers at all.
+type pausedLimit struct{}
+
+func (pausedLimit) Limit() int                   { return 0 }
+func (pausedLimit) Observe(time.Duration, error) {}
+
+func TestWorkerLimit_zero(t *testing.T) {
+	in, out := flowmatic.TaskPool(4, func(n int) (int, error) {
+		return n, nil
+	}, flowmatic.WorkerLimit(pausedLimit{}))
+	// The pool keeps one worker
+	for i := 0; i < 5; i++ {
+		in <- i
+		if r := <-out; r.Out != i {
+			t.Fatal(r)
+		}
+	}
+	close(in)
+	if _, ok := <-out; ok {
+		t.Fatal("out not closed")
+	}
+}
+
+// manualLimit is a custom Limiter that is changed by hand.
+type manualLimit struct {
+	mu sync.Mutex
+	n  int
+	ch chan struct{}
+}
+
+func newManualLimit(n int) *manualLimit {
+	return &manualLimit{n: n, ch: make(chan struct{})}
+}
+
+func (ml *manualLimit) Limit() int {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	return ml.n
+}
+
+func (ml *manualLimit) Observe(time.Duration, error) {}
+
+func (ml *manualLimit) Changed() <-chan struct{} {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	return ml.ch
+}
+
+func (ml *manualLimit) set(n int) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	ml.n = n
+	close(ml.ch)
+	ml.ch = make(chan struct{})
+}
+
+func TestLimitNotifier(t *testing.T) {
+	ml := newManualLimit(4)
+	var lt limitTracker
+	wait := barrier(4)
+	in, out := flowmatic.TaskPool(0, func(n int) (int, error) {
+		if n < 4 {
+			// Make sure all four workers are running
+			wait()
+			return n, nil
+		}
+		lt.run()
+		return n, nil
+	}, flowmatic.WorkerLimit(ml))
+	for i := 0; i < 4; i++ {
+		in <- i
+	}
+	for i := 0; i < 4; i++ {
+		<-out
+	}
+	// Shrink the pool while every worker is idle
+	ml.set(1)
+	time.Sleep(10 * time.Millisecond)
+	go func() {
+		defer close(in)
+		for i := 4; i < 24; i++ {
+			in <- i
+		}
+	}()
+	for range out {
+	}
+	if m := lt.max.Load(); m != 1 {
+		t.Fatalf("ran %d tasks at once with a limit of 1", m)
+	}
+}
+
+func TestDynamicLimit_zero(t *testing.T) {
+	var dl flowmatic.DynamicLimit
+	if l := dl.Limit(); l != 1 {
+		t.Fatal(l)
+	}
+	dl.Resize(3)
+	changed := dl.Changed()
+	dl.Resize(2)
+	select {
+	case <-changed:
+	default:
+		t.Fatal("not notified")
+	}
+	if l := dl.Limit(); l != 2 {
+		t.Fatal(l)
+	}
+	err := flowmatic.Each(0, []int{1, 2, 3}, func(int) error {
+		return nil
+	}, flowmatic.WorkerLimit(&dl))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
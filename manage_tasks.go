@@ -1,7 +1,10 @@
 package flowmatic
 
 import (
-	"github.com/carlmjohnson/deque"
+	"context"
+	"errors"
+	"runtime"
+	"time"
 )
 
 // Manager is a function that serially examines Task results to see if it produced any new Inputs.
@@ -15,38 +18,198 @@ type Task[Input, Output any] func(in Input) (out Output, err error)
 // which produce output consumed by a serially run manager.
 // The manager should return a slice of new task inputs based on prior task results,
 // or return false to halt processing.
+// By default, inputs run in the order they are queued.
+// See LIFO, PriorityOrder, and UseQueue for other orders,
+// Dedupe to skip inputs that were already queued,
+// and NotBefore to delay inputs.
 // If a task panics during execution,
-// the panic will be caught and rethrown in the parent Goroutine.
+// a *PanicError will be caught and rethrown in the parent Goroutine.
+// If a task calls runtime.Goexit,
+// processing halts
+// and the parent Goroutine calls runtime.Goexit.
 func ManageTasks[Input, Output any](numWorkers int, task Task[Input, Output], manager Manager[Input, Output], initial ...Input) {
-	in, out := TaskPool(numWorkers, task)
+	ManageTasksWith(numWorkers, task, manager, initial)
+}
+
+// ManageTasksWith is like ManageTasks, but takes a slice of initial inputs and ManageOptions.
+// See OnHalt to learn which inputs were left unprocessed when the manager halts.
+// Panics that are converted into errors by an Option
+// are passed to the manager as the task error.
+func ManageTasksWith[Input, Output any](numWorkers int, task Task[Input, Output], manager Manager[Input, Output], initial []Input, opts ...ManageOption[Input]) {
+	_ = manageTasks(context.Background(), numWorkers,
+		func(_ context.Context, in Input) (Output, error) {
+			return task(in)
+		},
+		func(in Input, out Output, err error) ([]Input, error) {
+			items, ok := manager(in, out, err)
+			if !ok {
+				return nil, ErrHalt
+			}
+			return items, nil
+		},
+		initial, newManageConfig(opts))
+}
+
+// ErrHalt can be returned by a ManagerContext
+// to halt the processing of future tasks without an error.
+var ErrHalt = errors.New("flowmatic: halted by manager")
+
+// ManagerContext is like Manager,
+// but it returns a non-nil error to halt the processing of future tasks.
+type ManagerContext[Input, Output any] func(Input, Output, error) (tasks []Input, err error)
+
+// ManageTasksContext is like ManageTasks,
+// but each task receives a child context
+// which is canceled once the manager halts
+// or ctx is canceled.
+// The cause of the cancellation,
+// as reported by context.Cause,
+// is the error returned by the manager.
+// ManageTasksContext waits for running tasks to return.
+// It returns the error returned by the manager,
+// or nil if that error is ErrHalt.
+// If ctx is canceled,
+// ManageTasksContext stops starting tasks
+// and returns context.Cause(ctx).
+func ManageTasksContext[Input, Output any](ctx context.Context, numWorkers int, task func(context.Context, Input) (Output, error), manager ManagerContext[Input, Output], initial ...Input) error {
+	return ManageTasksContextWith(ctx, numWorkers, task, manager, initial)
+}
+
+// ManageTasksContextWith is like ManageTasksContext, but takes a slice of initial inputs and ManageOptions.
+// Panics that are converted into errors by an Option
+// are passed to the manager as the task error.
+func ManageTasksContextWith[Input, Output any](ctx context.Context, numWorkers int, task func(context.Context, Input) (Output, error), manager ManagerContext[Input, Output], initial []Input, opts ...ManageOption[Input]) error {
+	err := manageTasks(ctx, numWorkers, task, manager, initial, newManageConfig(opts))
+	if errors.Is(err, ErrHalt) {
+		return nil
+	}
+	return err
+}
+
+// manageTasks runs the tasks for ManageTasks and ManageTasksContext
+// and returns the error that halted them.
+func manageTasks[Input, Output any](ctx context.Context, numWorkers int, task func(context.Context, Input) (Output, error), manager ManagerContext[Input, Output], initial []Input, cfg manageConfig[Input]) (err error) {
+	parent := ctx
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	queue := cfg.queue()
+	key := cfg.key
+	seen := make(map[any]bool)
+	when := cfg.notBefore
+	delayed := newDelayQueue[Input]()
+	defer delayed.Stop(false)
+	onHalt := cfg.onHalt
+	// The pool must not buffer inputs,
+	// or they would run after the manager halts.
+	poolcfg := cfg.poolConfig()
+	poolcfg.onPanic = cfg.onPanic
+	in, out := newTaskPool(numWorkers, stateless(func(in Input) (Output, error) {
+		return task(ctx, in)
+	}), poolcfg, nil)
+	var (
+		panics   PanicErrors
+		goexit   bool
+		finished bool
+		dropped  []Input
+	)
 	defer func() {
+		cancel(err)
 		close(in)
 		// drain any waiting tasks
-		for range out {
+		for r := range out {
+			if r.Panic != nil {
+				panics = append(panics, r.Panic.(*PanicError))
+			}
+			goexit = goexit || r.Goexit
+			dropped = append(dropped, r.In)
+		}
+		if !finished && onHalt != nil {
+			onHalt(Unprocessed[Input]{
+				Queued:   unqueue(queue, delayed),
+				InFlight: dropped,
+			})
+		}
+		panics.rethrow()
+		if goexit {
+			runtime.Goexit()
 		}
 	}()
-	queue := deque.Of(initial...)
+	// push queues item unless it is a duplicate
+	// or holds it back until it may run.
+	// If retrying is true, a duplicate of retry is queued anyway.
+	push := func(item Input, retry any, retrying bool) {
+		if key != nil {
+			k := key(item)
+			if seen[k] && !(retrying && k == retry) {
+				return
+			}
+			seen[k] = true
+		}
+		if when != nil {
+			if at := when(item); at.After(time.Now()) {
+				delayed.Push(at, item)
+				return
+			}
+		}
+		queue.Push(item)
+	}
+	for _, item := range initial {
+		push(item, nil, false)
+	}
 	inflight := 0
-	for inflight > 0 || queue.Len() > 0 {
+	for {
+		now := time.Now()
+		for {
+			item, ok := delayed.PopDue(now)
+			if !ok {
+				break
+			}
+			queue.Push(item)
+		}
+		item, ok := queue.Peek()
+		if !ok && inflight == 0 && delayed.Len() == 0 {
+			finished = true
+			return nil
+		}
 		inch := in
-		item, ok := queue.Head()
 		if !ok {
 			inch = nil
 		}
+		wait := delayed.Wait(now)
+		fired := false
 		select {
+		case <-wait:
+			fired = true
 		case inch <- item:
 			inflight++
-			queue.RemoveFront()
+			queue.Pop()
 		case r := <-out:
 			inflight--
 			if r.Panic != nil {
-				panic(r.Panic)
+				pe := r.Panic.(*PanicError)
+				panics = append(panics, pe)
+				cancel(pe)
+				return nil
 			}
-			items, ok := manager(r.In, r.Out, r.Err)
-			if !ok {
-				return
+			if r.Goexit {
+				goexit = true
+				return nil
+			}
+			items, err := manager(r.In, r.Out, r.Err)
+			if err != nil {
+				return err
+			}
+			var retry any
+			if key != nil {
+				retry = key(r.In)
+			}
+			for _, item := range items {
+				push(item, retry, key != nil)
 			}
-			queue.PushBackSlice(items)
+		case <-parent.Done():
+			return context.Cause(parent)
 		}
+		delayed.Stop(fired)
 	}
 }
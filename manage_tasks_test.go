@@ -1,6 +1,7 @@
 package flowmatic_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"testing"
@@ -46,3 +47,69 @@ func TestManageTasks_drainage(t *testing.T) {
 		t.Fatal("didn't finish")
 	}
 }
+
+func TestManageTasksContext(t *testing.T) {
+	errTooDeep := errors.New("too deep")
+	var seen []int
+	err := flowmatic.ManageTasksContext(context.Background(), 2,
+		func(ctx context.Context, n int) (int, error) {
+			if n < 0 {
+				// Wait for the manager to halt
+				<-ctx.Done()
+				return 0, context.Cause(ctx)
+			}
+			return n + 1, nil
+		},
+		func(in, out int, err error) ([]int, error) {
+			seen = append(seen, in)
+			if out == 4 {
+				return nil, errTooDeep
+			}
+			return []int{out}, nil
+		}, -1, 0)
+	if err != errTooDeep {
+		t.Fatal(err)
+	}
+	// The blocked task's result is not passed to the manager
+	if s := fmt.Sprint(seen); s != "[0 1 2 3]" {
+		t.Fatal(s)
+	}
+}
+
+func TestManageTasksContext_halt(t *testing.T) {
+	err := flowmatic.ManageTasksContext(context.Background(), 1,
+		func(ctx context.Context, n int) (int, error) {
+			return n, nil
+		},
+		func(in, out int, err error) ([]int, error) {
+			return []int{in + 1}, flowmatic.ErrHalt
+		}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestManageTasksContext_canceled(t *testing.T) {
+	errStop := errors.New("stop")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	calls := 0
+	err := flowmatic.ManageTasksContext(ctx, 1,
+		func(ctx context.Context, n int) (int, error) {
+			if n == 3 {
+				cancel(errStop)
+				<-ctx.Done()
+			}
+			return n, nil
+		},
+		func(in, out int, err error) ([]int, error) {
+			calls++
+			return []int{in + 1}, nil
+		}, 0)
+	if err != errStop {
+		t.Fatal(err)
+	}
+	if calls > 4 {
+		t.Fatal(calls)
+	}
+}
@@ -2,6 +2,7 @@ package flowmatic
 
 import (
 	"context"
+	"runtime"
 )
 
 // Map starts numWorkers concurrent workers (or GOMAXPROCS workers if numWorkers < 1)
@@ -10,21 +11,63 @@ import (
 // The first error or panic returned by a task
 // cancels the child context
 // and halts further task scheduling.
+// Map returns that error wrapped in a *TaskError.
+// The cause of the cancellation,
+// as reported by context.Cause,
+// is that error or *PanicError.
 // If a task panics during execution,
-// the panic will be caught and rethrown in the parent Goroutine.
-func Map[Input, Output any](ctx context.Context, numWorkers int, items []Input, task func(context.Context, Input) (Output, error)) (results []Output, err error) {
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
+// a *PanicError will be caught and rethrown in the parent Goroutine.
+// If a task calls runtime.Goexit,
+// Map halts as for a panic
+// and then calls runtime.Goexit in the parent Goroutine.
+func Map[Input, Output any](ctx context.Context, numWorkers int, items []Input, task func(context.Context, Input) (Output, error), opts ...Option) ([]Output, error) {
+	return mapWorker(ctx, numWorkers, items, func() (func(context.Context, Input) (Output, error), func()) {
+		return task, func() {}
+	}, newConfig(opts))
+}
 
-	inch, ouch := TaskPool(numWorkers, func(pos int) (Output, error) {
-		item := items[pos]
-		return task(ctx, item)
+// mapWorker is like Map, but each worker gets its task from start.
+func mapWorker[Input, Output any](ctx context.Context, numWorkers int, items []Input, start func() (task func(context.Context, Input) (Output, error), stop func()), cfg config) (results []Output, err error) {
+	results = make([]Output, len(items))
+	mapItems(ctx, numWorkers, items, start, cfg, func(pos int, out Output, taskErr error) error {
+		results[pos] = out
+		if taskErr != nil && err == nil {
+			err = wrapTaskError(pos, items[pos], taskErr)
+			return taskErr
+		}
+		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// mapItems runs a task from start on each item using a TaskPool
+// and serially passes each result to handle along with its position.
+// If handle returns a non-nil error,
+// mapItems cancels the child context with it as the cause
+// and halts further task scheduling.
+// Panics are handled according to cfg
+// and halt task scheduling if they are rethrown.
+// mapItems returns the number of items that were scheduled.
+func mapItems[Input, Output any](ctx context.Context, numWorkers int, items []Input, start func() (task func(context.Context, Input) (Output, error), stop func()), cfg config, handle func(pos int, out Output, err error) error) int {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	inch, ouch := newTaskPool(numWorkers, func() (Task[int, Output], func()) {
+		task, stop := start()
+		return func(pos int) (Output, error) {
+			return task(ctx, items[pos])
+		}, stop
+	}, cfg.poolConfig(), nil)
 
-	var panicVal any
+	var (
+		panics PanicErrors
+		goexit bool
+	)
 	n := 0
 	closeinch := false
-	results = make([]Output, len(items))
 
 	for {
 		if n >= len(items) {
@@ -39,25 +82,33 @@ func Map[Input, Output any](ctx context.Context, numWorkers int, items []Input,
 			n++
 		case r, ok := <-ouch:
 			if !ok {
-				if panicVal != nil {
-					panic(panicVal)
+				panics.rethrow()
+				if goexit {
+					runtime.Goexit()
 				}
-				if err != nil {
-					return nil, err
-				}
-				return results, nil
+				return n
 			}
-			if r.Err != nil && err == nil {
-				cancel()
+			if r.Goexit {
+				cancel(nil)
 				closeinch = true
-				err = r.Err
+				goexit = true
+				continue
+			}
+			if r.Panic != nil {
+				pe := r.Panic.(*PanicError)
+				pe.Index = r.In
+				pe.Input = items[r.In]
+				r.Err = cfg.handlePanic(pe, &panics)
+				if len(panics) > 0 {
+					cancel(pe)
+					closeinch = true
+					continue
+				}
 			}
-			if r.Panic != nil && panicVal == nil {
-				cancel()
+			if cause := handle(r.In, r.Out, r.Err); cause != nil {
+				cancel(cause)
 				closeinch = true
-				panicVal = r.Panic
 			}
-			results[r.In] = r.Out
 		}
 	}
 }
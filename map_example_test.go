@@ -79,3 +79,20 @@ func ExampleMap_simple() {
 	// Output:
 	// [0 2 84 2674]
 }
+
+func ExampleMapSettled() {
+	ctx := context.Background()
+	input := []string{"1", "two", "3"}
+	output, errs := flowmatic.MapSettled(ctx, flowmatic.MaxProcs, input,
+		func(ctx context.Context, s string) (int, error) {
+			return strconv.Atoi(s)
+		})
+	// Every item is processed, even after a failure
+	for i := range input {
+		fmt.Println(output[i], errs[i])
+	}
+	// Output:
+	// 1 <nil>
+	// 0 strconv.Atoi: parsing "two": invalid syntax
+	// 3 <nil>
+}
@@ -0,0 +1,54 @@
+package flowmatic
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrTooManyFailures is the error recorded by MapSettled
+// for items that were not run
+// because the MaxFailures limit was reached.
+// It is also the cause of the cancellation of the child context.
+var ErrTooManyFailures = errors.New("flowmatic: too many failures")
+
+// MapSettled starts numWorkers concurrent workers (or GOMAXPROCS workers if numWorkers < 1)
+// and maps the input slice to an output slice.
+// Each task receives a child context.
+// Unlike Map, errors returned by tasks do not halt execution.
+// MapSettled returns every output
+// along with a slice holding the error returned by the task for each item,
+// wrapped in a *TaskError.
+// If the MaxFailures Option is used,
+// the child context is canceled with ErrTooManyFailures
+// once that many tasks have failed,
+// and items that were not yet scheduled have ErrTooManyFailures,
+// wrapped in a *TaskError, as their error.
+// If a task panics during execution,
+// a *PanicError will be caught and rethrown in the parent Goroutine.
+// If a task calls runtime.Goexit,
+// MapSettled halts as for a panic
+// and then calls runtime.Goexit in the parent Goroutine.
+func MapSettled[Input, Output any](ctx context.Context, numWorkers int, items []Input, task func(context.Context, Input) (Output, error), opts ...Option) (results []Output, errs []error) {
+	cfg := newConfig(opts)
+	results = make([]Output, len(items))
+	errs = make([]error, len(items))
+	failures := 0
+	n := mapItems(ctx, numWorkers, items, func() (func(context.Context, Input) (Output, error), func()) {
+		return task, func() {}
+	}, cfg, func(pos int, out Output, err error) error {
+		results[pos] = out
+		errs[pos] = wrapTaskError(pos, items[pos], err)
+		if err == nil {
+			return nil
+		}
+		failures++
+		if cfg.maxFailures > 0 && failures == cfg.maxFailures {
+			return ErrTooManyFailures
+		}
+		return nil
+	})
+	for i := n; i < len(items); i++ {
+		errs[i] = wrapTaskError(i, items[i], ErrTooManyFailures)
+	}
+	return results, errs
+}
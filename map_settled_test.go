@@ -0,0 +1,45 @@
+package flowmatic_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/carlmjohnson/flowmatic"
+)
+
+func TestMapSettled(t *testing.T) {
+	ctx := context.Background()
+	o, errs := flowmatic.MapSettled(ctx, 2, []string{"1", "x", "3", "y"}, func(ctx context.Context, s string) (int, error) {
+		if ctx.Err() != nil {
+			t.Error("should not be canceled")
+		}
+		return strconv.Atoi(s)
+	})
+	if fmt.Sprint(o) != "[1 0 3 0]" {
+		t.Fatal(o)
+	}
+	if errs[0] != nil || errs[1] == nil || errs[2] != nil || errs[3] == nil {
+		t.Fatal(errs)
+	}
+}
+
+func TestMapSettled_MaxFailures(t *testing.T) {
+	ctx := context.Background()
+	o, errs := flowmatic.MapSettled(ctx, 1, []string{"1", "x", "y", "4", "5"}, func(ctx context.Context, s string) (int, error) {
+		return strconv.Atoi(s)
+	}, flowmatic.MaxFailures(2))
+	if o[0] != 1 || errs[0] != nil {
+		t.Fatal(o, errs)
+	}
+	if errs[1] == nil || errs[2] == nil {
+		t.Fatal(errs)
+	}
+	var te *flowmatic.TaskError[string]
+	if !errors.As(errs[4], &te) || te.Err != flowmatic.ErrTooManyFailures ||
+		te.Index != 4 || te.Input != "5" {
+		t.Fatal(errs)
+	}
+}
@@ -32,3 +32,22 @@ func TestMap(t *testing.T) {
 		t.Fatal(o)
 	}
 }
+
+func TestMap_cause(t *testing.T) {
+	ctx := context.Background()
+	a := errors.New("a")
+	_, err := flowmatic.Map(ctx, 2, []int{1, 2}, func(ctx context.Context, i int) (int, error) {
+		if i == 1 {
+			return 0, a
+		}
+		<-ctx.Done()
+		if cause := context.Cause(ctx); cause != a {
+			t.Error(cause)
+		}
+		return 0, ctx.Err()
+	})
+	var te *flowmatic.TaskError[int]
+	if !errors.As(err, &te) || te.Err != a || te.Index != 0 || te.Input != 1 {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,236 @@
+package flowmatic
+
+import (
+	"runtime"
+	"time"
+)
+
+// Option configures optional behavior of a flowmatic helper.
+type Option func(*config)
+
+type config struct {
+	onPanic      func(*PanicError) error
+	numWorkers   int
+	maxFailures  int
+	ordered      bool
+	window       int
+	queueSize    int
+	overflow     Overflow
+	outputBuffer int
+	limit        Limiter
+	maxTasks     int
+	maxAge       time.Duration
+}
+
+// ManageOption configures optional behavior of ManageTasksWith and ManageTasksContextWith
+// for inputs of type Input.
+// See PoolOptions to use an Option as a ManageOption.
+type ManageOption[Input any] func(*manageConfig[Input])
+
+type manageConfig[Input any] struct {
+	config
+	newQueue  func() Queue[Input]
+	key       func(Input) any
+	notBefore func(Input) time.Time
+	onHalt    func(Unprocessed[Input])
+}
+
+func newManageConfig[Input any](opts []ManageOption[Input]) manageConfig[Input] {
+	var cfg manageConfig[Input]
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// PoolOptions returns a ManageOption that applies opts,
+// such as PanicsAsErrors, WorkerLimit, or RecycleWorkers,
+// to ManageTasks.
+// Options that set up TaskPool's own buffers,
+// such as QueueSize, OnOverflow, OutputBuffer, and Ordered,
+// are ignored.
+func PoolOptions[Input any](opts ...Option) ManageOption[Input] {
+	return func(cfg *manageConfig[Input]) {
+		for _, opt := range opts {
+			opt(&cfg.config)
+		}
+	}
+}
+
+func newConfig(opts []Option) config {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// Workers returns an Option that limits DoWith, DoContextWith, AllWith, and RaceWith
+// to running numWorkers tasks at once (or GOMAXPROCS tasks if numWorkers < 1).
+// By default, they start every task at once.
+// Helpers that take a numWorkers argument ignore this Option.
+func Workers(numWorkers int) Option {
+	if numWorkers < 1 {
+		numWorkers = runtime.GOMAXPROCS(0)
+	}
+	return func(cfg *config) {
+		cfg.numWorkers = numWorkers
+	}
+}
+
+// workers returns the number of workers to use for numTasks tasks.
+func (cfg *config) workers(numTasks int) int {
+	if cfg.numWorkers == 0 || cfg.numWorkers > numTasks {
+		return numTasks
+	}
+	return cfg.numWorkers
+}
+
+// MaxFailures returns an Option that makes MapSettled halt
+// once n tasks have failed.
+// By default, MapSettled runs every item.
+func MaxFailures(n int) Option {
+	return func(cfg *config) {
+		cfg.maxFailures = n
+	}
+}
+
+// Ordered returns an Option that makes TaskPool send Results
+// in the order their inputs were received.
+// Results that finish early are held until the Results before them are sent.
+// To keep memory bounded,
+// TaskPool stops accepting new inputs
+// while window Results (or 2 × numWorkers Results if window < 1) are pending.
+func Ordered(window int) Option {
+	return func(cfg *config) {
+		cfg.ordered = true
+		cfg.window = window
+	}
+}
+
+// QueueSize returns an Option that gives TaskPool a queue
+// holding up to n inputs waiting for a free worker.
+// By default, there is no queue
+// and sending on the in channel blocks until a worker is free.
+// See Overflow for what happens when the queue is full.
+func QueueSize(n int) Option {
+	return func(cfg *config) {
+		cfg.queueSize = n
+	}
+}
+
+// OutputBuffer returns an Option that sets the capacity
+// of the out channel of TaskPool to n.
+// By default, it is numWorkers.
+func OutputBuffer(n int) Option {
+	return func(cfg *config) {
+		cfg.outputBuffer = n
+	}
+}
+
+// Overflow is a policy for what TaskPool does
+// with an input that arrives while its queue is full.
+// See OnOverflow.
+type Overflow int
+
+const (
+	// Block makes sends on the in channel wait until there is room in the queue.
+	// This is the default.
+	Block Overflow = iota
+	// Reject sends a Result with ErrQueueFull for the new input.
+	Reject
+	// DropOldest removes the oldest input from the queue
+	// to make room for the new input,
+	// and sends a Result with ErrDropped for the oldest input.
+	DropOldest
+	// CallerRuns runs the task for the new input
+	// on a Goroutine that the pool's intake waits for
+	// instead of waiting for a free worker,
+	// so further sends on the in channel block until it finishes.
+	CallerRuns
+)
+
+// OnOverflow returns an Option that sets
+// what TaskPool does with an input that arrives while its queue is full.
+func OnOverflow(policy Overflow) Option {
+	return func(cfg *config) {
+		cfg.overflow = policy
+	}
+}
+
+// WorkerLimit returns an Option that makes TaskPool, Each, Map, ManageTasks,
+// and related helpers take their number of workers from l
+// instead of from their numWorkers argument,
+// so that it can change while they run.
+// See DynamicLimit and AIMDLimit.
+func WorkerLimit(l Limiter) Option {
+	return func(cfg *config) {
+		cfg.limit = l
+	}
+}
+
+// RecycleWorkers returns an Option that makes TaskPool, Each, Map, ManageTasks,
+// and related helpers replace each worker
+// once it has run maxTasks tasks
+// or maxAge has passed since its first task started,
+// whichever comes first.
+// A busy worker is replaced after finishing its current task
+// and an idle one right away.
+// The worker's State, as in TaskPoolState,
+// is closed and created anew by the replacement.
+// A limit of zero or less is ignored.
+func RecycleWorkers(maxTasks int, maxAge time.Duration) Option {
+	return func(cfg *config) {
+		cfg.maxTasks = maxTasks
+		cfg.maxAge = maxAge
+	}
+}
+
+// poolConfig returns the Options that helpers pass on to their internal TaskPool.
+// Helpers handle the other Options themselves.
+func (cfg *config) poolConfig() config {
+	return config{
+		limit:    cfg.limit,
+		maxTasks: cfg.maxTasks,
+		maxAge:   cfg.maxAge,
+	}
+}
+
+// RethrowPanics returns an Option that catches task panics
+// and rethrows them in the parent Goroutine.
+// This is the default.
+func RethrowPanics() Option {
+	return func(cfg *config) {
+		cfg.onPanic = nil
+	}
+}
+
+// PanicsAsErrors returns an Option that catches task panics
+// and treats the resulting *PanicError as the error returned by the task.
+func PanicsAsErrors() Option {
+	return OnPanic(func(pe *PanicError) error {
+		return pe
+	})
+}
+
+// OnPanic returns an Option that catches task panics
+// and reports them to fn instead of rethrowing them.
+// The error returned by fn is treated as the error returned by the task,
+// so returning nil ignores the panic.
+// Fn may be called concurrently from multiple Goroutines.
+func OnPanic(fn func(*PanicError) error) Option {
+	return func(cfg *config) {
+		cfg.onPanic = fn
+	}
+}
+
+// handlePanic applies the panic policy to pe.
+// It returns the error to use in place of the panic
+// or records pe to be rethrown.
+func (cfg *config) handlePanic(pe *PanicError, panics *PanicErrors) error {
+	if cfg.onPanic == nil {
+		*panics = append(*panics, pe)
+		return nil
+	}
+	return cfg.onPanic(pe)
+}
@@ -0,0 +1,106 @@
+package flowmatic
+
+import (
+	"cmp"
+	"fmt"
+	"runtime/debug"
+	"slices"
+	"strings"
+)
+
+// PanicError is the value rethrown in the parent Goroutine
+// when a task panics.
+// It records the value originally passed to panic
+// along with the stack trace of the Goroutine where the panic occurred.
+// If more than one task panics, PanicErrors is rethrown instead.
+type PanicError struct {
+	// Index is the position of the task or item that panicked,
+	// or -1 if the position is not known.
+	Index int
+	// Input is the input of the task that panicked, if any.
+	// For helpers that run heterogeneous tasks, such as Do, All, and Race,
+	// it is the task function itself.
+	Input any
+	// Value is the value originally passed to panic.
+	Value any
+	// Stack is the stack trace of the task Goroutine at the time of the panic.
+	Stack []byte
+}
+
+// newPanicError must be called from the deferred function that recovered val
+// so that the stack trace includes the frames that panicked.
+func newPanicError(index int, input, val any) *PanicError {
+	return &PanicError{
+		Index: index,
+		Input: input,
+		Value: val,
+		Stack: debug.Stack(),
+	}
+}
+
+// recovered carries a *PanicError built by a task wrapper
+// that had to see the panic before the pool did.
+// The pool reports its PanicError as is.
+type recovered struct{ pe *PanicError }
+
+func (pe *PanicError) Error() string {
+	if pe.Index < 0 {
+		return fmt.Sprintf("flowmatic: task panicked: %v\n\n%s", pe.Value, pe.Stack)
+	}
+	return fmt.Sprintf("flowmatic: task %d panicked: %v\n\n%s", pe.Index, pe.Value, pe.Stack)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (pe *PanicError) Unwrap() error {
+	err, _ := pe.Value.(error)
+	return err
+}
+
+// PanicErrors is the value rethrown in the parent Goroutine
+// when more than one task panics.
+// The panics are ordered by task index.
+type PanicErrors []*PanicError
+
+func (pes PanicErrors) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "flowmatic: %d tasks panicked", len(pes))
+	for _, pe := range pes {
+		sb.WriteString("\n\n")
+		sb.WriteString(pe.Error())
+	}
+	return sb.String()
+}
+
+// Unwrap returns each *PanicError as an error.
+func (pes PanicErrors) Unwrap() []error {
+	errs := make([]error, len(pes))
+	for i, pe := range pes {
+		errs[i] = pe
+	}
+	return errs
+}
+
+// Values returns the values originally passed to panic.
+func (pes PanicErrors) Values() []any {
+	vals := make([]any, len(pes))
+	for i, pe := range pes {
+		vals[i] = pe.Value
+	}
+	return vals
+}
+
+// rethrow does nothing if there are no panics,
+// panics with the *PanicError if there is only one,
+// and otherwise panics with all of them in order.
+func (pes PanicErrors) rethrow() {
+	switch len(pes) {
+	case 0:
+		return
+	case 1:
+		panic(pes[0])
+	}
+	slices.SortStableFunc(pes, func(a, b *PanicError) int {
+		return cmp.Compare(a.Index, b.Index)
+	})
+	panic(pes)
+}
@@ -2,9 +2,14 @@ package flowmatic_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"runtime"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/carlmjohnson/flowmatic"
 )
@@ -17,6 +22,23 @@ func try(f func()) (r any) {
 	return
 }
 
+// panicErr asserts that r is a *PanicError with the given value
+// whose stack trace points into the test task.
+func panicErr(t *testing.T, r any, val any) *flowmatic.PanicError {
+	t.Helper()
+	pe, ok := r.(*flowmatic.PanicError)
+	if !ok {
+		t.Fatalf("%T: %v", r, r)
+	}
+	if pe.Value != val {
+		t.Fatal(pe.Value)
+	}
+	if !strings.Contains(string(pe.Stack), "panic_test.go") {
+		t.Fatalf("bad stack: %s", pe.Stack)
+	}
+	return pe
+}
+
 func TestManageTasks_panic(t *testing.T) {
 	task := func(n int) (int, error) {
 		if n == 3 {
@@ -35,8 +57,8 @@ func TestManageTasks_panic(t *testing.T) {
 	if r == nil {
 		t.Fatal("should have panicked")
 	}
-	if r != "3!!" {
-		t.Fatal(r)
+	if pe := panicErr(t, r, "3!!"); pe.Input != 3 {
+		t.Fatal(pe.Input)
 	}
 	if fmt.Sprint(triples) != "[3 6]" {
 		t.Fatal(triples)
@@ -64,8 +86,8 @@ func TestEach_panic(t *testing.T) {
 	if r == nil {
 		t.Fatal("should have panicked")
 	}
-	if r != "boom" {
-		t.Fatal(r)
+	if pe := panicErr(t, r, "boom"); pe.Index != 1 || pe.Input != int64(2) {
+		t.Fatal(pe.Index, pe.Input)
 	}
 	if n.Load() != 4 {
 		t.Fatal(n.Load())
@@ -97,8 +119,8 @@ func TestDo_panic(t *testing.T) {
 	if r == nil {
 		t.Fatal("should have panicked")
 	}
-	if r != "boom" {
-		t.Fatal(r)
+	if pe := panicErr(t, r, "boom"); pe.Index != 1 {
+		t.Fatal(pe.Index)
 	}
 	if n.Load() != 2 {
 		t.Fatal(n.Load())
@@ -130,8 +152,8 @@ func TestRace_panic(t *testing.T) {
 	if r == nil {
 		t.Fatal("should have panicked")
 	}
-	if r != "boom" {
-		t.Fatal(r)
+	if pe := panicErr(t, r, "boom"); pe.Index != 1 {
+		t.Fatal(pe.Index)
 	}
 	if n.Load() != 2 {
 		t.Fatal(n.Load())
@@ -163,8 +185,8 @@ func TestAll_panic(t *testing.T) {
 	if r == nil {
 		t.Fatal("should have panicked")
 	}
-	if r != "boom" {
-		t.Fatal(r)
+	if pe := panicErr(t, r, "boom"); pe.Index != 1 {
+		t.Fatal(pe.Index)
 	}
 	if n.Load() != 2 {
 		t.Fatal(n.Load())
@@ -192,10 +214,409 @@ func TestMap_panic(t *testing.T) {
 	if r == nil {
 		t.Fatal("should have panicked")
 	}
-	if r != "boom" {
-		t.Fatal(r)
+	if pe := panicErr(t, r, "boom"); pe.Index != 1 || pe.Input != int64(2) {
+		t.Fatal(pe.Index, pe.Input)
+	}
+	if o != nil {
+		t.Fatal(o)
+	}
+}
+
+func TestPanicError_unwrap(t *testing.T) {
+	boom := errors.New("boom")
+	r := try(func() {
+		_ = flowmatic.Do(func() error {
+			panic(boom)
+		})
+	})
+	pe := panicErr(t, r, boom)
+	if !errors.Is(pe, boom) {
+		t.Fatal(pe)
+	}
+	if !strings.Contains(pe.Error(), "panic_test.go") {
+		t.Fatal(pe.Error())
+	}
+}
+
+func TestTaskPool_panic(t *testing.T) {
+	in, out := flowmatic.TaskPool(1, func(s string) (int, error) {
+		panic(s)
+	})
+	go func() {
+		in <- "boom"
+		close(in)
+	}()
+	r := <-out
+	if pe := panicErr(t, r.Panic, "boom"); pe.Input != "boom" || pe.Index != -1 {
+		t.Fatal(pe.Index, pe.Input)
+	}
+	if _, ok := <-out; ok {
+		t.Fatal("expected closed channel")
+	}
+}
+
+// panicErrs asserts that r is PanicErrors with the given values in order.
+func panicErrs(t *testing.T, r any, want string) flowmatic.PanicErrors {
+	t.Helper()
+	pes, ok := r.(flowmatic.PanicErrors)
+	if !ok {
+		t.Fatalf("%T: %v", r, r)
+	}
+	if got := fmt.Sprint(pes.Values()); got != want {
+		t.Fatal(got)
+	}
+	for i, pe := range pes {
+		if !errors.Is(pes, pe) {
+			t.Fatal(i, pe)
+		}
+	}
+	return pes
+}
+
+// barrier returns a function that blocks until it has been called n times.
+func barrier(n int) func() {
+	var wg sync.WaitGroup
+	wg.Add(n)
+	return func() {
+		wg.Done()
+		wg.Wait()
+	}
+}
+
+func TestDo_multipanic(t *testing.T) {
+	wait := barrier(2)
+	r := try(func() {
+		_ = flowmatic.Do(
+			func() error {
+				wait()
+				panic("a")
+			},
+			func() error {
+				return nil
+			},
+			func() error {
+				wait()
+				panic("b")
+			})
+	})
+	pes := panicErrs(t, r, "[a b]")
+	if pes[0].Index != 0 || pes[1].Index != 2 {
+		t.Fatal(pes[0].Index, pes[1].Index)
+	}
+}
+
+func TestEach_multipanic(t *testing.T) {
+	wait := barrier(3)
+	r := try(func() {
+		_ = flowmatic.Each(3, []string{"a", "b", "c"}, func(s string) error {
+			wait()
+			panic(s)
+		})
+	})
+	pes := panicErrs(t, r, "[a b c]")
+	for i, pe := range pes {
+		if pe.Index != i {
+			t.Fatal(i, pe.Index)
+		}
+	}
+}
+
+func TestAll_multipanic(t *testing.T) {
+	wait := barrier(2)
+	r := try(func() {
+		_ = flowmatic.All(context.Background(),
+			func(context.Context) error {
+				wait()
+				panic("a")
+			},
+			func(context.Context) error {
+				wait()
+				panic("b")
+			})
+	})
+	panicErrs(t, r, "[a b]")
+}
+
+func TestMap_multipanic(t *testing.T) {
+	wait := barrier(2)
+	ctx := context.Background()
+	r := try(func() {
+		_, _ = flowmatic.Map(ctx, 2, []string{"a", "b", "c"},
+			func(_ context.Context, s string) (string, error) {
+				if s == "c" {
+					return s, nil
+				}
+				wait()
+				panic(s)
+			})
+	})
+	pes := panicErrs(t, r, "[a b]")
+	if pes[1].Input != "b" {
+		t.Fatal(pes[1].Input)
+	}
+}
+
+func TestManageTasks_multipanic(t *testing.T) {
+	wait := barrier(2)
+	r := try(func() {
+		flowmatic.ManageTasks(2,
+			func(s string) (string, error) {
+				wait()
+				panic(s)
+			},
+			func(string, string, error) ([]string, bool) {
+				t.Error("manager should not be called")
+				return nil, true
+			},
+			"a", "b")
+	})
+	pes, ok := r.(flowmatic.PanicErrors)
+	if !ok || len(pes) != 2 {
+		t.Fatalf("%T: %v", r, r)
+	}
+}
+
+func TestDoWith_PanicsAsErrors(t *testing.T) {
+	a := errors.New("a")
+	err := flowmatic.DoWith([]func() error{
+		func() error { return a },
+		func() error { panic("boom") },
+	}, flowmatic.PanicsAsErrors())
+	if !errors.Is(err, a) {
+		t.Fatal(err)
+	}
+	var pe *flowmatic.PanicError
+	if !errors.As(err, &pe) || pe.Value != "boom" || pe.Index != 1 {
+		t.Fatal(err)
+	}
+}
+
+func TestEach_OnPanic(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		seen []any
+	)
+	err := flowmatic.Each(1, []string{"a", "b", "c"}, func(s string) error {
+		if s != "b" {
+			panic(s)
+		}
+		return nil
+	}, flowmatic.OnPanic(func(pe *flowmatic.PanicError) error {
+		mu.Lock()
+		defer mu.Unlock()
+		seen = append(seen, pe.Input)
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(seen) != "[a c]" {
+		t.Fatal(seen)
+	}
+}
+
+func TestAllWith_PanicsAsErrors(t *testing.T) {
+	err := flowmatic.AllWith(context.Background(), []func(context.Context) error{
+		func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		},
+		func(context.Context) error { panic("boom") },
+	}, flowmatic.PanicsAsErrors())
+	var pe *flowmatic.PanicError
+	if !errors.As(err, &pe) || pe.Value != "boom" {
+		t.Fatal(err)
+	}
+}
+
+func TestRaceWith_PanicsAsErrors(t *testing.T) {
+	a := errors.New("a")
+	err := flowmatic.RaceWith(context.Background(), []func(context.Context) error{
+		func(context.Context) error { return a },
+		func(context.Context) error { panic("boom") },
+	}, flowmatic.PanicsAsErrors())
+	var pe *flowmatic.PanicError
+	if !errors.Is(err, a) || !errors.As(err, &pe) || pe.Value != "boom" {
+		t.Fatal(err)
+	}
+}
+
+func TestRaceWith_PanicsAsErrors_success(t *testing.T) {
+	err := flowmatic.RaceWith(context.Background(), []func(context.Context) error{
+		func(context.Context) error { panic("boom") },
+		func(ctx context.Context) error {
+			// A converted panic must not cancel a slower sibling
+			select {
+			case <-time.After(20 * time.Millisecond):
+				return nil
+			case <-ctx.Done():
+				return context.Cause(ctx)
+			}
+		},
+	}, flowmatic.PanicsAsErrors())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMap_PanicsAsErrors(t *testing.T) {
+	ctx := context.Background()
+	o, err := flowmatic.Map(ctx, 1, []int{1, 2, 3},
+		func(_ context.Context, n int) (int, error) {
+			if n == 2 {
+				panic("boom")
+			}
+			return n, nil
+		}, flowmatic.PanicsAsErrors())
+	var pe *flowmatic.PanicError
+	if !errors.As(err, &pe) || pe.Input != 2 {
+		t.Fatal(err)
 	}
 	if o != nil {
 		t.Fatal(o)
 	}
 }
+
+func TestManageTasksWith_PanicsAsErrors(t *testing.T) {
+	var errs []error
+	flowmatic.ManageTasksWith(1,
+		func(n int) (int, error) {
+			if n == 2 {
+				panic("boom")
+			}
+			return n, nil
+		},
+		func(_, _ int, err error) ([]int, bool) {
+			errs = append(errs, err)
+			return nil, true
+		},
+		[]int{1, 2, 3}, flowmatic.PoolOptions[int](flowmatic.PanicsAsErrors()))
+	if len(errs) != 3 || errs[0] != nil || errs[2] != nil {
+		t.Fatal(errs)
+	}
+	var pe *flowmatic.PanicError
+	if !errors.As(errs[1], &pe) || pe.Input != 2 {
+		t.Fatal(errs[1])
+	}
+}
+
+// goexits reports whether f called runtime.Goexit instead of returning.
+func goexits(f func()) bool {
+	done := make(chan bool)
+	go func() {
+		returned := false
+		defer func() {
+			done <- !returned
+		}()
+		f()
+		returned = true
+	}()
+	return <-done
+}
+
+func TestGoexit(t *testing.T) {
+	ctx := context.Background()
+	var n atomic.Int64
+	cases := map[string]func(){
+		"Do": func() {
+			_ = flowmatic.Do(
+				func() error {
+					runtime.Goexit()
+					return nil
+				},
+				func() error {
+					n.Add(1)
+					return nil
+				})
+		},
+		"Each": func() {
+			_ = flowmatic.Each(1, []int{1, 2, 3}, func(i int) error {
+				if i == 2 {
+					runtime.Goexit()
+				}
+				n.Add(1)
+				return nil
+			})
+		},
+		"All": func() {
+			_ = flowmatic.All(ctx,
+				func(context.Context) error {
+					runtime.Goexit()
+					return nil
+				},
+				func(ctx context.Context) error {
+					<-ctx.Done()
+					n.Add(1)
+					return nil
+				})
+		},
+		"Race": func() {
+			_ = flowmatic.Race(ctx,
+				func(context.Context) error {
+					runtime.Goexit()
+					return nil
+				},
+				func(ctx context.Context) error {
+					<-ctx.Done()
+					n.Add(1)
+					return nil
+				})
+		},
+		"Map": func() {
+			_, _ = flowmatic.Map(ctx, 1, []int{1, 2}, func(ctx context.Context, i int) (int, error) {
+				if i == 1 {
+					n.Add(1)
+					runtime.Goexit()
+				}
+				return i, nil
+			})
+		},
+		"ManageTasks": func() {
+			flowmatic.ManageTasks(1,
+				func(i int) (int, error) {
+					if i == 1 {
+						n.Add(1)
+						runtime.Goexit()
+					}
+					return i, nil
+				},
+				func(int, int, error) ([]int, bool) {
+					t.Error("manager should not be called")
+					return nil, true
+				},
+				1, 2)
+		},
+	}
+	for name, f := range cases {
+		t.Run(name, func(t *testing.T) {
+			n.Store(0)
+			if !goexits(f) {
+				t.Fatal("should have exited")
+			}
+			if n.Load() == 0 {
+				t.Fatal("other tasks did not run")
+			}
+		})
+	}
+}
+
+func TestTaskPool_goexit(t *testing.T) {
+	in, out := flowmatic.TaskPool(1, func(i int) (int, error) {
+		if i == 1 {
+			runtime.Goexit()
+		}
+		return i * 2, nil
+	})
+	go func() {
+		in <- 1
+		in <- 2
+		close(in)
+	}()
+	var rs []string
+	for r := range out {
+		rs = append(rs, fmt.Sprint(r.In, r.Out, r.Goexit))
+	}
+	if fmt.Sprint(rs) != "[1 0 true 2 4 false]" {
+		t.Fatal(rs)
+	}
+}
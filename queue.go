@@ -0,0 +1,134 @@
+package flowmatic
+
+import (
+	"container/heap"
+
+	"github.com/carlmjohnson/deque"
+)
+
+// Queue holds the inputs waiting to be run by ManageTasks.
+// Push adds an input.
+// Peek returns the next input to run without removing it,
+// and Pop removes and returns it.
+// Both return false if the queue is empty.
+// A Queue is only used by one Goroutine at a time.
+type Queue[Input any] interface {
+	Push(Input)
+	Peek() (Input, bool)
+	Pop() (Input, bool)
+}
+
+// FIFO returns a ManageOption that makes ManageTasks run inputs
+// in the order they were queued,
+// which processes work breadth-first.
+// This is the default.
+func FIFO[Input any]() ManageOption[Input] {
+	return func(cfg *manageConfig[Input]) {
+		cfg.newQueue = nil
+	}
+}
+
+// LIFO returns a ManageOption that makes ManageTasks run
+// the most recently queued input first,
+// which processes work depth-first
+// and keeps the queue short.
+func LIFO[Input any]() ManageOption[Input] {
+	return func(cfg *manageConfig[Input]) {
+		cfg.newQueue = func() Queue[Input] {
+			return lifoQueue[Input]{deque.Make[Input](0)}
+		}
+	}
+}
+
+// PriorityOrder returns a ManageOption that makes ManageTasks run
+// the queued input that sorts first by less.
+// Inputs that sort equally run in the order they were queued.
+func PriorityOrder[Input any](less func(a, b Input) bool) ManageOption[Input] {
+	return func(cfg *manageConfig[Input]) {
+		cfg.newQueue = func() Queue[Input] {
+			return &priorityQueue[Input]{less: less}
+		}
+	}
+}
+
+// UseQueue returns a ManageOption that makes ManageTasks keep its waiting inputs in q.
+func UseQueue[Input any](q Queue[Input]) ManageOption[Input] {
+	return func(cfg *manageConfig[Input]) {
+		cfg.newQueue = func() Queue[Input] {
+			return q
+		}
+	}
+}
+
+// queue returns a Queue of the kind selected by cfg.
+func (cfg *manageConfig[Input]) queue() Queue[Input] {
+	if cfg.newQueue == nil {
+		return fifoQueue[Input]{deque.Make[Input](0)}
+	}
+	return cfg.newQueue()
+}
+
+type fifoQueue[Input any] struct{ d *deque.Deque[Input] }
+
+func (q fifoQueue[Input]) Push(in Input)       { q.d.PushBack(in) }
+func (q fifoQueue[Input]) Peek() (Input, bool) { return q.d.Front() }
+func (q fifoQueue[Input]) Pop() (Input, bool)  { return q.d.RemoveFront() }
+
+type lifoQueue[Input any] struct{ d *deque.Deque[Input] }
+
+func (q lifoQueue[Input]) Push(in Input)       { q.d.PushBack(in) }
+func (q lifoQueue[Input]) Peek() (Input, bool) { return q.d.Back() }
+func (q lifoQueue[Input]) Pop() (Input, bool)  { return q.d.RemoveBack() }
+
+// priorityQueue is a heap ordered by less,
+// with ties broken by the order of insertion.
+type priorityQueue[Input any] struct {
+	less  func(a, b Input) bool
+	items []indexed[Input]
+	pos   int
+}
+
+func (q *priorityQueue[Input]) Push(in Input) {
+	heap.Push((*priorityHeap[Input])(q), indexed[Input]{q.pos, in})
+	q.pos++
+}
+
+func (q *priorityQueue[Input]) Peek() (in Input, ok bool) {
+	if len(q.items) == 0 {
+		return in, false
+	}
+	return q.items[0].item, true
+}
+
+func (q *priorityQueue[Input]) Pop() (in Input, ok bool) {
+	if len(q.items) == 0 {
+		return in, false
+	}
+	return heap.Pop((*priorityHeap[Input])(q)).(indexed[Input]).item, true
+}
+
+// priorityHeap implements heap.Interface for priorityQueue.
+type priorityHeap[Input any] priorityQueue[Input]
+
+func (h *priorityHeap[Input]) Len() int { return len(h.items) }
+
+func (h *priorityHeap[Input]) Less(i, j int) bool {
+	a, b := h.items[i], h.items[j]
+	if h.less(a.item, b.item) {
+		return true
+	}
+	if h.less(b.item, a.item) {
+		return false
+	}
+	return a.pos < b.pos
+}
+
+func (h *priorityHeap[Input]) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *priorityHeap[Input]) Push(x any) { h.items = append(h.items, x.(indexed[Input])) }
+
+func (h *priorityHeap[Input]) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
@@ -0,0 +1,74 @@
+package flowmatic_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/carlmjohnson/flowmatic"
+)
+
+// stack is a user Queue.
+type stack struct {
+	items  []int
+	pushes int
+}
+
+func (s *stack) Push(n int) {
+	s.pushes++
+	s.items = append(s.items, n)
+}
+
+func (s *stack) Peek() (int, bool) {
+	if len(s.items) == 0 {
+		return 0, false
+	}
+	return s.items[len(s.items)-1], true
+}
+
+func (s *stack) Pop() (int, bool) {
+	n, ok := s.Peek()
+	if ok {
+		s.items = s.items[:len(s.items)-1]
+	}
+	return n, ok
+}
+
+func TestManageTasks_queue(t *testing.T) {
+	var st stack
+	cases := map[string]struct {
+		opt  flowmatic.ManageOption[int]
+		want string
+	}{
+		"default": {nil, "[0 3 1 2 10 30 20]"},
+		"FIFO":    {flowmatic.FIFO[int](), "[0 3 1 2 10 30 20]"},
+		"LIFO":    {flowmatic.LIFO[int](), "[0 20 30 10 2 1 3]"},
+		"priority": {flowmatic.PriorityOrder(func(a, b int) bool {
+			return a%10 < b%10
+		}), "[0 10 30 20 1 2 3]"},
+		"queue": {flowmatic.UseQueue[int](&st), "[0 20 30 10 2 1 3]"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var opts []flowmatic.ManageOption[int]
+			if tc.opt != nil {
+				opts = append(opts, tc.opt)
+			}
+			var order []int
+			flowmatic.ManageTasksWith(1,
+				func(n int) (int, error) { return n, nil },
+				func(in, out int, err error) ([]int, bool) {
+					order = append(order, in)
+					if in == 0 {
+						return []int{3, 1, 2, 10, 30, 20}, true
+					}
+					return nil, true
+				}, []int{0}, opts...)
+			if s := fmt.Sprint(order); s != tc.want {
+				t.Fatal(s)
+			}
+		})
+	}
+	if st.pushes != 7 {
+		t.Fatal(st.pushes)
+	}
+}
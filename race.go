@@ -6,40 +6,57 @@ import (
 	"sync/atomic"
 )
 
+// ErrRaceWon is the cause of the cancellation of the child context in Race
+// once a task has succeeded.
+var ErrRaceWon = errors.New("flowmatic: race won by another task")
+
 // Race runs each task concurrently
 // and waits for them all to finish.
 // Each function receives a child context
 // which is canceled once one function has successfully completed or panicked.
+// After a success, context.Cause of the child context is ErrRaceWon.
 // Race returns nil
 // if at least one function completes without an error.
 // If all functions return an error,
-// Race returns a multierror containing all the errors.
+// Race returns a multierror containing all the errors,
+// each wrapped in a *TaskError,
+// in the order of the tasks.
 // If a function panics during execution,
-// a panic will be caught and rethrown in the parent Goroutine.
+// a *PanicError will be caught and rethrown in the parent Goroutine.
+// If a task calls runtime.Goexit,
+// the parent Goroutine calls runtime.Goexit once all tasks are finished.
 func Race(ctx context.Context, tasks ...func(context.Context) error) error {
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-	errs := make([]error, len(tasks))
+	return RaceWith(ctx, tasks)
+}
+
+// RaceWith is like Race, but takes a slice of tasks and Options.
+// Panics that are converted into errors by an Option
+// count as failures.
+func RaceWith(ctx context.Context, tasks []func(context.Context) error, opts ...Option) error {
+	cfg := newConfig(opts)
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
 	var success atomic.Bool
-	_ = eachN(len(tasks), len(tasks), func(pos int) error {
+	err := eachN(cfg.workers(len(tasks)), tasks, func(pos int) error {
+		returned := false
 		defer func() {
-			panicVal := recover()
-			if panicVal != nil {
-				cancel()
-				panic(panicVal)
+			// Cancel siblings if the task panicked,
+			// unless the panic only counts as a failure
+			if !returned && cfg.onPanic == nil {
+				cancel(nil)
 			}
 		}()
 		err := tasks[pos](ctx)
+		returned = true
 		if err != nil {
-			errs[pos] = err
-			return nil
+			return err
 		}
-		cancel()
+		cancel(ErrRaceWon)
 		success.Store(true)
 		return nil
-	})
+	}, cfg)
 	if success.Load() {
 		return nil
 	}
-	return errors.Join(errs...)
+	return err
 }
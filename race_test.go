@@ -47,3 +47,21 @@ func TestRace_join_errs(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestRace_cause(t *testing.T) {
+	err := flowmatic.Race(context.Background(),
+		func(ctx context.Context) error {
+			return nil
+		},
+		func(ctx context.Context) error {
+			<-ctx.Done()
+			if cause := context.Cause(ctx); cause != flowmatic.ErrRaceWon {
+				t.Error(cause)
+			}
+			return ctx.Err()
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,131 @@
+package flowmatic
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy configures the retry wrappers RetryContext, RetryMap, and RetryTask.
+// The zero value tries each task up to three times without waiting.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a task is tried, including the first.
+	// If MaxAttempts < 1, a task is tried three times.
+	MaxAttempts int
+	// Delay is how long to wait before the second attempt.
+	// The wait doubles before each further attempt.
+	Delay time.Duration
+	// MaxDelay caps the wait between attempts.
+	// If MaxDelay is zero, there is no cap.
+	MaxDelay time.Duration
+	// Jitter is the fraction of each wait, from 0 to 1,
+	// that is replaced with a random duration,
+	// so that failed tasks do not all retry at once.
+	Jitter float64
+	// Retryable reports whether a task that returned err should be tried again.
+	// If Retryable is nil, every error is retried.
+	Retryable func(err error) bool
+}
+
+type attemptKey struct{}
+
+// Attempt returns the number of the current attempt, starting at 1,
+// of a task run by a retry wrapper with ctx,
+// or 0 if ctx did not come from a retry wrapper.
+func Attempt(ctx context.Context) int {
+	n, _ := ctx.Value(attemptKey{}).(int)
+	return n
+}
+
+// RetryContext wraps a task for All, Race, or DoContext
+// so that it is tried again according to rp when it returns an error.
+// Waits between attempts end early if ctx is canceled,
+// in which case the last error is joined with the cause of the cancellation.
+// Each attempt receives a context that reports its number to Attempt.
+func RetryContext(rp RetryPolicy, task func(context.Context) error) func(context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := retry(ctx, rp, func(ctx context.Context) (struct{}, error) {
+			return struct{}{}, task(ctx)
+		})
+		return err
+	}
+}
+
+// RetryMap is like RetryContext,
+// but wraps a task for Map, EachContext, MapSeq, or TaskPoolContext.
+func RetryMap[Input, Output any](rp RetryPolicy, task func(context.Context, Input) (Output, error)) func(context.Context, Input) (Output, error) {
+	return func(ctx context.Context, in Input) (Output, error) {
+		return retry(ctx, rp, func(ctx context.Context) (Output, error) {
+			return task(ctx, in)
+		})
+	}
+}
+
+// RetryTask is like RetryMap,
+// but returns a Task for TaskPool or ManageTasks
+// that passes ctx to each attempt.
+func RetryTask[Input, Output any](ctx context.Context, rp RetryPolicy, task func(context.Context, Input) (Output, error)) Task[Input, Output] {
+	retryTask := RetryMap(rp, task)
+	return func(in Input) (Output, error) {
+		return retryTask(ctx, in)
+	}
+}
+
+// retry calls task until it succeeds,
+// returns an error that rp does not retry,
+// runs out of attempts,
+// or ctx is canceled.
+func retry[Output any](ctx context.Context, rp RetryPolicy, task func(context.Context) (Output, error)) (Output, error) {
+	maxAttempts := rp.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 3
+	}
+	delay := rp.Delay
+	for attempt := 1; ; attempt++ {
+		out, err := task(context.WithValue(ctx, attemptKey{}, attempt))
+		if err == nil || attempt >= maxAttempts ||
+			(rp.Retryable != nil && !rp.Retryable(err)) {
+			return out, err
+		}
+		if sleep(ctx, rp.jitter(delay)) != nil {
+			return out, errors.Join(err, context.Cause(ctx))
+		}
+		// Stop doubling before delay overflows
+		if delay <= math.MaxInt64/2 {
+			delay *= 2
+		}
+		if rp.MaxDelay > 0 && delay > rp.MaxDelay {
+			delay = rp.MaxDelay
+		}
+	}
+}
+
+// jitter replaces a random part of d according to rp.Jitter.
+func (rp RetryPolicy) jitter(d time.Duration) time.Duration {
+	j := time.Duration(float64(d) * min(max(rp.Jitter, 0), 1))
+	if j <= 0 {
+		return d
+	}
+	return d - j + time.Duration(rand.Int63n(int64(j)+1))
+}
+
+// sleep waits for d or until ctx is canceled,
+// in which case it returns ctx.Err().
+func sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,85 @@
+package flowmatic_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/flowmatic"
+)
+
+func TestRetryContext(t *testing.T) {
+	errFlaky := errors.New("flaky")
+	var attempts []int
+	task := flowmatic.RetryContext(flowmatic.RetryPolicy{
+		MaxAttempts: 4,
+		Delay:       time.Millisecond,
+		Jitter:      0.5,
+	}, func(ctx context.Context) error {
+		attempts = append(attempts, flowmatic.Attempt(ctx))
+		if len(attempts) < 3 {
+			return errFlaky
+		}
+		return nil
+	})
+	if err := flowmatic.All(context.Background(), task); err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 3 || attempts[0] != 1 || attempts[2] != 3 {
+		t.Fatal(attempts)
+	}
+	if flowmatic.Attempt(context.Background()) != 0 {
+		t.Fatal("attempt outside retry")
+	}
+}
+
+func TestRetryMap(t *testing.T) {
+	errFatal := errors.New("fatal")
+	errFlaky := errors.New("flaky")
+	calls := map[string]int{}
+	rp := flowmatic.RetryPolicy{
+		Retryable: func(err error) bool {
+			return !errors.Is(err, errFatal)
+		},
+	}
+	task := flowmatic.RetryMap(rp, func(ctx context.Context, s string) (int, error) {
+		calls[s]++
+		switch s {
+		case "fatal":
+			return 0, errFatal
+		case "flaky":
+			return 0, errFlaky
+		}
+		return len(s), nil
+	})
+	for _, s := range []string{"ok", "fatal", "flaky"} {
+		_, _ = task(context.Background(), s)
+	}
+	// The zero policy makes three attempts
+	if calls["ok"] != 1 || calls["fatal"] != 1 || calls["flaky"] != 3 {
+		t.Fatal(calls)
+	}
+}
+
+func TestRetryTask_canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	errFlaky := errors.New("flaky")
+	calls := 0
+	task := flowmatic.RetryTask(ctx, flowmatic.RetryPolicy{
+		MaxAttempts: 10,
+		Delay:       time.Hour,
+	}, func(ctx context.Context, n int) (int, error) {
+		calls++
+		cancel()
+		return 0, errFlaky
+	})
+	start := time.Now()
+	_, err := task(1)
+	if !errors.Is(err, errFlaky) || !errors.Is(err, context.Canceled) {
+		t.Fatal(err)
+	}
+	if calls != 1 || time.Since(start) > time.Second {
+		t.Fatal(calls, time.Since(start))
+	}
+}
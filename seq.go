@@ -0,0 +1,171 @@
+//go:build go1.23
+
+package flowmatic
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"runtime"
+)
+
+// EachSeq starts numWorkers concurrent workers (or GOMAXPROCS workers if numWorkers < 1)
+// and processes each item in the sequence as a task.
+// Items are pulled from the sequence only as workers become free.
+// Errors returned by a task do not halt execution,
+// but are wrapped in a *TaskError and joined into a multierror return value
+// in the order of the items.
+// If a task panics during execution,
+// a *PanicError will be caught and rethrown in the parent Goroutine.
+// If a task calls runtime.Goexit,
+// the parent Goroutine calls runtime.Goexit once all tasks are finished.
+func EachSeq[Input any](numWorkers int, items iter.Seq[Input], task func(Input) error, opts ...Option) error {
+	cfg := newConfig(opts)
+	inch, ouch := newTaskPool(numWorkers, stateless(func(in indexed[Input]) (void, error) {
+		return void{}, task(in.item)
+	}), cfg.poolConfig(), nil)
+	var (
+		panics PanicErrors
+		errs   []error
+		goexit bool
+	)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for r := range ouch {
+			if r.Goexit {
+				goexit = true
+			}
+			if r.Panic != nil {
+				pe := r.Panic.(*PanicError)
+				pe.Index = r.In.pos
+				pe.Input = r.In.item
+				r.Err = cfg.handlePanic(pe, &panics)
+			}
+			if r.Err != nil {
+				for len(errs) <= r.In.pos {
+					errs = append(errs, nil)
+				}
+				errs[r.In.pos] = wrapTaskError(r.In.pos, r.In.item, r.Err)
+			}
+		}
+	}()
+	func() {
+		defer close(inch)
+		pos := 0
+		for item := range items {
+			inch <- indexed[Input]{pos, item}
+			pos++
+		}
+	}()
+	<-done
+	panics.rethrow()
+	if goexit {
+		runtime.Goexit()
+	}
+	return errors.Join(errs...)
+}
+
+// MapSeq starts numWorkers concurrent workers (or GOMAXPROCS workers if numWorkers < 1)
+// and returns a sequence of the output and error of the task for each item,
+// in the order of the items.
+// Each task receives a child context.
+// Items are pulled from the input sequence only as workers become free,
+// and no more than 2 × numWorkers items are run ahead of the output.
+// Errors returned by tasks are wrapped in a *TaskError
+// and do not halt execution.
+// If the consumer stops iterating,
+// the child context is canceled
+// and the workers are stopped before iteration returns.
+// If a task panics during execution,
+// processing halts
+// and a *PanicError will be caught and rethrown in the consuming Goroutine.
+// If a task calls runtime.Goexit,
+// processing halts
+// and the consuming Goroutine calls runtime.Goexit.
+func MapSeq[Input, Output any](ctx context.Context, numWorkers int, items iter.Seq[Input], task func(context.Context, Input) (Output, error), opts ...Option) iter.Seq2[Output, error] {
+	cfg := newConfig(opts)
+	return func(yield func(Output, error) bool) {
+		ctx, cancel := context.WithCancelCause(ctx)
+		defer cancel(nil)
+
+		next, stop := iter.Pull(items)
+		defer stop()
+
+		poolcfg := cfg.poolConfig()
+		poolcfg.ordered = true
+		inch, ouch := newTaskPool(numWorkers, stateless(func(in Input) (Output, error) {
+			return task(ctx, in)
+		}), poolcfg, nil)
+		var (
+			panics PanicErrors
+			goexit bool
+			pos    int
+		)
+		defer func() {
+			// stop any running tasks and wait for them
+			cancel(nil)
+			if inch != nil {
+				close(inch)
+			}
+			for r := range ouch {
+				if r.Panic != nil {
+					pe := r.Panic.(*PanicError)
+					pe.Index = pos
+					_ = cfg.handlePanic(pe, &panics)
+				}
+				goexit = goexit || r.Goexit
+				pos++
+			}
+			panics.rethrow()
+			if goexit {
+				runtime.Goexit()
+			}
+		}()
+
+		var (
+			item     Input
+			haveItem bool
+		)
+		for {
+			if inch != nil && !haveItem {
+				item, haveItem = next()
+				if !haveItem {
+					close(inch)
+					inch = nil
+				}
+			}
+			sendch := inch
+			if !haveItem {
+				sendch = nil
+			}
+			select {
+			case sendch <- item:
+				haveItem = false
+			case r, ok := <-ouch:
+				if !ok {
+					return
+				}
+				i := pos
+				pos++
+				if r.Goexit {
+					goexit = true
+					return
+				}
+				if r.Panic != nil {
+					pe := r.Panic.(*PanicError)
+					pe.Index = i
+					r.Err = cfg.handlePanic(pe, &panics)
+					if len(panics) > 0 {
+						cancel(pe)
+						return
+					}
+				}
+				err := wrapTaskError(i, r.In, r.Err)
+				if !yield(r.Out, err) {
+					return
+				}
+			}
+		}
+	}
+}
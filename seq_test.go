@@ -0,0 +1,123 @@
+//go:build go1.23
+
+package flowmatic_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/flowmatic"
+)
+
+// countTo returns a sequence of the numbers from 1 to n
+// that records how many numbers have been pulled.
+func countTo(n int, pulled *atomic.Int64) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for i := 1; i <= n; i++ {
+			pulled.Add(1)
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+func TestEachSeq(t *testing.T) {
+	var pulled, sum atomic.Int64
+	err := flowmatic.EachSeq(3, countTo(10, &pulled), func(i int) error {
+		sum.Add(int64(i))
+		if i%4 == 0 {
+			return fmt.Errorf("%d", i)
+		}
+		return nil
+	})
+	if err == nil || err.Error() != "4\n8" {
+		t.Fatalf("%q", err)
+	}
+	if sum.Load() != 55 {
+		t.Fatal(sum.Load())
+	}
+}
+
+func TestMapSeq(t *testing.T) {
+	ctx := context.Background()
+	items := slices.Values([]string{"1", "x", "3", "4"})
+	var (
+		outs []int
+		errs []error
+	)
+	for out, err := range flowmatic.MapSeq(ctx, 2, items, func(ctx context.Context, s string) (int, error) {
+		// Finish out of order
+		n, err := strconv.Atoi(s)
+		time.Sleep(time.Duration(5-n) * time.Millisecond)
+		return n, err
+	}) {
+		outs = append(outs, out)
+		errs = append(errs, err)
+	}
+	if fmt.Sprint(outs) != "[1 0 3 4]" {
+		t.Fatal(outs)
+	}
+	var te *flowmatic.TaskError[string]
+	if errs[0] != nil || !errors.As(errs[1], &te) || te.Index != 1 || te.Input != "x" {
+		t.Fatal(errs)
+	}
+}
+
+func TestMapSeq_break(t *testing.T) {
+	ctx := context.Background()
+	var pulled, running atomic.Int64
+	for out := range flowmatic.MapSeq(ctx, 2, countTo(1000, &pulled), func(ctx context.Context, i int) (int, error) {
+		if i == 1 {
+			return i, nil
+		}
+		running.Add(1)
+		defer running.Add(-1)
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}) {
+		if out != 1 {
+			t.Fatal(out)
+		}
+		break
+	}
+	// Workers are stopped before the loop exits
+	if n := running.Load(); n != 0 {
+		t.Fatal(n)
+	}
+	// And the input is only pulled as needed
+	if n := pulled.Load(); n > 5 {
+		t.Fatal(n)
+	}
+}
+
+func TestMapSeq_panic(t *testing.T) {
+	ctx := context.Background()
+	r := try(func() {
+		for range flowmatic.MapSeq(ctx, 1, slices.Values([]int{1, 2, 3}), func(ctx context.Context, i int) (int, error) {
+			if i == 2 {
+				panic("boom")
+			}
+			return i, nil
+		}) {
+		}
+	})
+	pe, ok := r.(*flowmatic.PanicError)
+	if !ok {
+		t.Fatalf("%T: %v", r, r)
+	}
+	if pe.Value != "boom" || pe.Index != 1 || pe.Input != 2 {
+		t.Fatal(pe.Value, pe.Index, pe.Input)
+	}
+	if !strings.Contains(string(pe.Stack), "seq_test.go") {
+		t.Fatalf("bad stack: %s", pe.Stack)
+	}
+}
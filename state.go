@@ -0,0 +1,88 @@
+package flowmatic
+
+import "context"
+
+// TaskPoolState is like TaskPool,
+// but each worker has its own State.
+// A worker calls init before running its first task
+// and passes the State to every task it runs.
+// Once the worker exits,
+// it calls close with the State, if close is not nil.
+// If init returns an error,
+// the Result for the input has that error,
+// and the worker calls init again before its next task.
+// Panics and calls to runtime.Goexit in init
+// are handled like those in a task.
+func TaskPoolState[State, Input, Output any](numWorkers int, init func() (State, error), task func(State, Input) (Output, error), close func(State), opts ...Option) (in chan<- Input, out <-chan Result[Input, Output]) {
+	return newTaskPool(numWorkers, stateful(
+		func(Input) (State, error) { return init() },
+		task,
+		close,
+	), newConfig(opts), nil)
+}
+
+// EachState is like Each,
+// but each worker has its own State,
+// as in TaskPoolState.
+// Errors returned by init are reported like task errors
+// for the item the worker was about to process.
+func EachState[State, Input any](numWorkers int, items []Input, init func() (State, error), task func(State, Input) error, close func(State), opts ...Option) error {
+	return eachWorker(numWorkers, items, stateful(
+		func(int) (State, error) { return init() },
+		func(s State, pos int) (void, error) { return void{}, task(s, items[pos]) },
+		close,
+	), newConfig(opts))
+}
+
+// MapState is like Map,
+// but each worker has its own State,
+// as in TaskPoolState.
+// Init receives the same child context as the tasks.
+// An error returned by init halts Map like a task error
+// for the item the worker was about to process.
+func MapState[State, Input, Output any](ctx context.Context, numWorkers int, items []Input, init func(context.Context) (State, error), task func(context.Context, State, Input) (Output, error), close func(State), opts ...Option) ([]Output, error) {
+	type ctxInput struct {
+		ctx context.Context
+		in  Input
+	}
+	start := stateful(
+		func(c ctxInput) (State, error) { return init(c.ctx) },
+		func(s State, c ctxInput) (Output, error) { return task(c.ctx, s, c.in) },
+		close,
+	)
+	return mapWorker(ctx, numWorkers, items, func() (func(context.Context, Input) (Output, error), func()) {
+		task, stop := start()
+		return func(ctx context.Context, in Input) (Output, error) {
+			return task(ctxInput{ctx, in})
+		}, stop
+	}, newConfig(opts))
+}
+
+// stateful returns a starter for workers that each have their own State.
+// A worker calls init with its first input
+// and closes the State when it exits.
+func stateful[State, Input, Output any](init func(Input) (State, error), task func(State, Input) (Output, error), close func(State)) starter[Input, Output] {
+	return func() (Task[Input, Output], func()) {
+		var (
+			state State
+			ready bool
+		)
+		run := func(in Input) (Output, error) {
+			if !ready {
+				s, err := init(in)
+				if err != nil {
+					var zero Output
+					return zero, err
+				}
+				state, ready = s, true
+			}
+			return task(state, in)
+		}
+		stop := func() {
+			if ready && close != nil {
+				close(state)
+			}
+		}
+		return run, stop
+	}
+}
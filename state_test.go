@@ -0,0 +1,176 @@
+package flowmatic_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/flowmatic"
+)
+
+// workerStates tracks the States created and closed by workers.
+type workerStates struct {
+	mu     sync.Mutex
+	inits  int
+	closed int
+	seen   int
+}
+
+func (ws *workerStates) init() (*[]int, error) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.inits++
+	return new([]int), nil
+}
+
+func (ws *workerStates) close(s *[]int) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.closed++
+	ws.seen += len(*s)
+}
+
+func TestTaskPoolState(t *testing.T) {
+	const numTasks, numWorkers = 100, 3
+	var ws workerStates
+	in, out := flowmatic.TaskPoolState(numWorkers, ws.init,
+		func(s *[]int, n int) (int, error) {
+			*s = append(*s, n)
+			return 2 * n, nil
+		}, ws.close)
+	go func() {
+		for i := 0; i < numTasks; i++ {
+			in <- i
+		}
+		close(in)
+	}()
+	sum := 0
+	for r := range out {
+		sum += r.Out
+	}
+	if sum != numTasks*(numTasks-1) {
+		t.Fatal(sum)
+	}
+	if ws.inits < 1 || ws.inits > numWorkers {
+		t.Fatal(ws.inits)
+	}
+	if ws.closed != ws.inits || ws.seen != numTasks {
+		t.Fatal(ws.closed, ws.seen)
+	}
+}
+
+func TestEachState_initError(t *testing.T) {
+	var ws workerStates
+	var calls atomic.Int64
+	errInit := errors.New("no connection")
+	items := make([]int, 20)
+	err := flowmatic.EachState(4, items,
+		func() (*[]int, error) {
+			if calls.Add(1) == 1 {
+				return nil, errInit
+			}
+			return ws.init()
+		},
+		func(s *[]int, n int) error {
+			*s = append(*s, n)
+			return nil
+		}, ws.close)
+	var te *flowmatic.TaskError[int]
+	if !errors.Is(err, errInit) || !errors.As(err, &te) {
+		t.Fatal(err)
+	}
+	if ws.closed != ws.inits || ws.seen != len(items)-1 {
+		t.Fatal(ws.inits, ws.closed, ws.seen)
+	}
+}
+
+func TestMapState(t *testing.T) {
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "prefix")
+	var closed atomic.Int64
+	res, err := flowmatic.MapState(ctx, 2, []int{1, 2, 3},
+		func(ctx context.Context) (string, error) {
+			return ctx.Value(key{}).(string), nil
+		},
+		func(ctx context.Context, prefix string, n int) (string, error) {
+			return prefix + strconv.Itoa(n), nil
+		},
+		func(string) { closed.Add(1) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"prefix1", "prefix2", "prefix3"}; !slices.Equal(res, want) {
+		t.Fatal(res)
+	}
+	if n := closed.Load(); n < 1 || n > 2 {
+		t.Fatal(n)
+	}
+}
+
+func TestRecycleWorkers(t *testing.T) {
+	const numTasks, maxTasks = 50, 4
+	var ws workerStates
+	err := flowmatic.EachState(2, make([]int, numTasks), ws.init,
+		func(s *[]int, n int) error {
+			if len(*s) >= maxTasks {
+				return errors.New("worker not recycled")
+			}
+			*s = append(*s, n)
+			return nil
+		}, ws.close, flowmatic.RecycleWorkers(maxTasks, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ws.inits < numTasks/maxTasks || ws.closed != ws.inits || ws.seen != numTasks {
+		t.Fatal(ws.inits, ws.closed, ws.seen)
+	}
+}
+
+func TestRecycleWorkers_age(t *testing.T) {
+	var ws workerStates
+	in, out := flowmatic.TaskPoolState(1, ws.init,
+		func(s *[]int, n int) (int, error) {
+			time.Sleep(2 * time.Millisecond)
+			*s = append(*s, n)
+			return len(*s), nil
+		}, ws.close, flowmatic.RecycleWorkers(0, time.Millisecond))
+	go func() {
+		for i := 0; i < 5; i++ {
+			in <- i
+		}
+		close(in)
+	}()
+	for r := range out {
+		// Each task outlives the worker's maximum age
+		if r.Out != 1 {
+			t.Fatal(r.Out)
+		}
+	}
+	if ws.inits != 5 || ws.closed != 5 {
+		t.Fatal(ws.inits, ws.closed)
+	}
+}
+
+func TestRecycleWorkers_idle(t *testing.T) {
+	var inits atomic.Int64
+	in, out := flowmatic.TaskPoolState(1,
+		func() (int64, error) { return inits.Add(1), nil },
+		func(id int64, _ int) (int64, error) { return id, nil },
+		nil, flowmatic.RecycleWorkers(0, 10*time.Millisecond))
+	defer close(in)
+	in <- 0
+	if r := <-out; r.Out != 1 {
+		t.Fatal(r.Out)
+	}
+	// The worker outlives its maximum age while idle
+	time.Sleep(50 * time.Millisecond)
+	in <- 1
+	if r := <-out; r.Out != 2 {
+		t.Fatal(r.Out)
+	}
+}
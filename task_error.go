@@ -0,0 +1,32 @@
+package flowmatic
+
+// TaskError records which task returned an error.
+// Helpers wrap the errors returned by tasks in a *TaskError,
+// so callers can use errors.As to find the items that failed.
+// For helpers that run heterogeneous tasks, such as Do, All, and Race,
+// Input is the task function itself.
+type TaskError[Input any] struct {
+	// Index is the position of the task or item that failed.
+	Index int
+	// Input is the input of the task that failed.
+	Input Input
+	// Err is the error returned by the task.
+	Err error
+}
+
+// Error returns the message of Err unchanged.
+func (te *TaskError[Input]) Error() string {
+	return te.Err.Error()
+}
+
+func (te *TaskError[Input]) Unwrap() error {
+	return te.Err
+}
+
+// wrapTaskError wraps a non-nil err in a *TaskError.
+func wrapTaskError[Input any](index int, input Input, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &TaskError[Input]{index, input, err}
+}
@@ -0,0 +1,64 @@
+package flowmatic_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/carlmjohnson/flowmatic"
+)
+
+// failed returns the inputs of the TaskErrors in err.
+func failed[Input any](err error) []Input {
+	var inputs []Input
+	if u, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range u.Unwrap() {
+			inputs = append(inputs, failed[Input](err)...)
+		}
+		return inputs
+	}
+	var te *flowmatic.TaskError[Input]
+	if errors.As(err, &te) {
+		inputs = append(inputs, te.Input)
+	}
+	return inputs
+}
+
+func TestTaskError(t *testing.T) {
+	items := []string{"1", "x", "3", "y"}
+	err := flowmatic.Each(1, items, func(s string) error {
+		_, err := strconv.Atoi(s)
+		return err
+	})
+	var numErr *strconv.NumError
+	if !errors.As(err, &numErr) {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(failed[string](err)); got != "[x y]" {
+		t.Fatal(got)
+	}
+
+	a := errors.New("a")
+	tasks := []func() error{
+		func() error { return nil },
+		func() error { return a },
+	}
+	err = flowmatic.Do(tasks...)
+	var te *flowmatic.TaskError[func() error]
+	if !errors.As(err, &te) || te.Index != 1 || te.Err != a {
+		t.Fatal(err)
+	}
+	if err := te.Input(); err != a {
+		t.Fatal("Input should be the failed task")
+	}
+
+	err = flowmatic.Race(context.Background(),
+		func(context.Context) error { return a },
+		func(context.Context) error { return a },
+	)
+	if n := len(failed[func(context.Context) error](err)); n != 2 {
+		t.Fatal(n)
+	}
+}
@@ -1,55 +1,492 @@
 package flowmatic
 
 import (
+	"context"
+	"errors"
 	"runtime"
 	"sync"
+	"time"
+
+	"github.com/carlmjohnson/deque"
 )
 
 // Result is the type returned by the output channel of TaskPool.
+// If the task panicked, Panic holds a *PanicError describing the panic.
+// If the task called runtime.Goexit, Goexit is true.
 type Result[Input, Output any] struct {
-	In    Input
-	Out   Output
-	Err   error
-	Panic any
+	In     Input
+	Out    Output
+	Err    error
+	Panic  any
+	Goexit bool
 }
 
+// ErrQueueFull is the error in the Result for an input
+// that TaskPool rejected because its queue was full.
+// See Overflow.
+var ErrQueueFull = errors.New("flowmatic: task queue is full")
+
+// ErrDropped is the error in the Result for an input
+// that TaskPool dropped from its queue to make room for a newer input.
+// See Overflow.
+var ErrDropped = errors.New("flowmatic: task dropped from queue")
+
 // TaskPool starts numWorkers workers (or GOMAXPROCS workers if numWorkers < 1) which consume
 // the in channel, execute task, and send the Result on the out channel.
 // Callers should close the in channel to stop the workers from waiting for tasks.
 // The out channel will be closed once the last result has been sent.
-func TaskPool[Input, Output any](numWorkers int, task Task[Input, Output]) (in chan<- Input, out <-chan Result[Input, Output]) {
+// If an Option sets a panic policy,
+// a panicking task's Result has the error from the policy in Err
+// instead of a *PanicError in Panic.
+// If a task calls runtime.Goexit,
+// its worker is replaced
+// and the Result has Goexit set.
+// By default, Results are sent in the order tasks finish.
+// See Ordered to send them in the order of the inputs instead.
+// See QueueSize, OnOverflow, and OutputBuffer
+// to let inputs and Results wait in buffers.
+// See WorkerLimit to change the number of workers while the pool runs
+// and RecycleWorkers to replace workers periodically.
+func TaskPool[Input, Output any](numWorkers int, task Task[Input, Output], opts ...Option) (in chan<- Input, out <-chan Result[Input, Output]) {
+	return newTaskPool(numWorkers, stateless(task), newConfig(opts), nil)
+}
+
+// TaskPoolContext is like TaskPool,
+// but each task receives ctx.
+// Once ctx is canceled,
+// the workers stop consuming the in channel,
+// Results that nobody receives are discarded,
+// and the out channel is closed as soon as running tasks return,
+// without the caller needing to drain it.
+// Callers should stop sending on the in channel once ctx is canceled.
+func TaskPoolContext[Input, Output any](ctx context.Context, numWorkers int, task func(context.Context, Input) (Output, error), opts ...Option) (in chan<- Input, out <-chan Result[Input, Output]) {
+	return newTaskPool(numWorkers, stateless(func(in Input) (Output, error) {
+		return task(ctx, in)
+	}), newConfig(opts), ctx.Done())
+}
+
+// starter returns the task for a new worker
+// and a function to call once the worker exits.
+type starter[Input, Output any] func() (task Task[Input, Output], stop func())
+
+// stateless returns a starter for workers that all run the same task.
+func stateless[Input, Output any](task Task[Input, Output]) starter[Input, Output] {
+	return func() (Task[Input, Output], func()) {
+		return task, func() {}
+	}
+}
+
+// newTaskPool starts a TaskPool configured by cfg.
+func newTaskPool[Input, Output any](numWorkers int, start starter[Input, Output], cfg config, done <-chan struct{}) (in chan<- Input, out <-chan Result[Input, Output]) {
+	if cfg.limit != nil {
+		numWorkers = workerLimit(cfg.limit)
+	}
 	if numWorkers < 1 {
 		numWorkers = runtime.GOMAXPROCS(0)
 	}
-	inch := make(chan Input)
-	ouch := make(chan Result[Input, Output], numWorkers)
-	var wg sync.WaitGroup
-	wg.Add(numWorkers)
+	if cfg.ordered {
+		return orderedTaskPool(numWorkers, start, cfg, done)
+	}
+	return taskPool(numWorkers, start, cfg, done)
+}
+
+// taskPool starts the workers for TaskPool.
+// Once done is closed,
+// workers stop taking inputs and stop waiting to send Results.
+func taskPool[Input, Output any](numWorkers int, start starter[Input, Output], cfg config, done <-chan struct{}) (in chan<- Input, out <-chan Result[Input, Output]) {
+	outSize := numWorkers
+	if cfg.outputBuffer > 0 {
+		outSize = cfg.outputBuffer
+	}
+	p := &pool[Input, Output]{
+		start: start,
+		cfg:   cfg,
+		done:  done,
+		ouch:  make(chan Result[Input, Output], outSize),
+	}
+	var inch chan Input
+	if cfg.overflow == Block {
+		inch = make(chan Input, cfg.queueSize)
+		p.work = inch
+	} else {
+		inch = make(chan Input)
+		p.work = make(chan Input)
+		p.wg.Add(1)
+		go p.intake(inch)
+	}
+	p.workers = numWorkers
+	p.wg.Add(numWorkers)
 	for i := 0; i < numWorkers; i++ {
-		go func() {
-			defer wg.Done()
-			for inval := range inch {
-				func() {
-					defer func() {
-						pval := recover()
-						if pval == nil {
-							return
-						}
-						ouch <- Result[Input, Output]{
-							In:    inval,
-							Panic: pval,
-						}
-					}()
-
-					outval, err := task(inval)
-					ouch <- Result[Input, Output]{inval, outval, err, nil}
-				}()
-			}
-		}()
+		go p.worker()
+	}
+	finished := make(chan struct{})
+	if cfg.limit != nil {
+		go p.resizer(finished)
+	}
+	go func() {
+		p.wg.Wait()
+		close(p.ouch)
+		close(finished)
+	}()
+	return inch, p.ouch
+}
+
+// pool holds the shared state of the workers of a TaskPool.
+type pool[Input, Output any] struct {
+	start starter[Input, Output]
+	cfg   config
+	done  <-chan struct{}
+	work  chan Input
+	ouch  chan Result[Input, Output]
+	wg    sync.WaitGroup
+
+	// mu guards workers and stopped,
+	// which track the pool's size for cfg.limit.
+	mu      sync.Mutex
+	workers int
+	stopped bool
+}
+
+// send sends r unless the pool is done.
+func (p *pool[Input, Output]) send(r Result[Input, Output]) {
+	select {
+	case p.ouch <- r:
+	case <-p.done:
+	}
+}
+
+// worker runs tasks from the work channel until it is closed or the pool is done.
+func (p *pool[Input, Output]) worker() {
+	defer p.wg.Done()
+	task, stop := p.start()
+	defer stop()
+	var (
+		tasks   int
+		started time.Time
+		aged    <-chan time.Time
+	)
+	for {
+		changed, ok := p.keepWorking()
+		if !ok {
+			return
+		}
+		select {
+		case <-changed:
+			// Check whether this worker should retire
+		case <-aged:
+			// This worker grew old while idle
+			p.replace()
+			return
+		case inval, ok := <-p.work:
+			if !ok {
+				p.stop()
+				return
+			}
+			if tasks == 0 && p.cfg.maxAge > 0 {
+				// A worker's age counts from its first task
+				started = time.Now()
+				timer := time.NewTimer(p.cfg.maxAge)
+				defer timer.Stop()
+				aged = timer.C
+			}
+			// If the task calls runtime.Goexit,
+			// this Goroutine exits,
+			// so start a replacement before it does.
+			p.run(inval, task, p.replace)
+			if p.cfg.limit != nil {
+				// The task's Observation may have raised the limit
+				p.grow()
+			}
+			tasks++
+			if p.worn(tasks, started) {
+				p.replace()
+				return
+			}
+		case <-p.done:
+			p.stop()
+			return
+		}
+	}
+}
+
+// replace starts a fresh worker in place of one that is exiting.
+func (p *pool[Input, Output]) replace() {
+	p.wg.Add(1)
+	go p.worker()
+}
+
+// worn reports whether a worker whose first task started at started
+// and has run tasks tasks should be recycled.
+func (p *pool[Input, Output]) worn(tasks int, started time.Time) bool {
+	return (p.cfg.maxTasks > 0 && tasks >= p.cfg.maxTasks) ||
+		(p.cfg.maxAge > 0 && time.Since(started) >= p.cfg.maxAge)
+}
+
+// keepWorking reports whether a worker should take another task.
+// If there are more workers than the limit allows,
+// it retires the worker instead.
+// While the worker waits for its next task,
+// changed is closed if the limit changes.
+func (p *pool[Input, Output]) keepWorking() (changed <-chan struct{}, ok bool) {
+	if p.cfg.limit == nil {
+		return nil, true
+	}
+	changed = limitChanged(p.cfg.limit)
+	limit := workerLimit(p.cfg.limit)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.workers > limit {
+		p.workers--
+		return nil, false
 	}
+	return changed, true
+}
+
+// stop records that the pool is shutting down,
+// so no new workers should be started.
+func (p *pool[Input, Output]) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopped = true
+}
+
+// grow starts new workers until there are as many as cfg.limit allows.
+func (p *pool[Input, Output]) grow() {
+	limit := workerLimit(p.cfg.limit)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for !p.stopped && p.workers < limit {
+		p.workers++
+		p.wg.Add(1)
+		go p.worker()
+	}
+}
+
+// resizer grows the pool whenever cfg.limit reports a change
+// until the pool is finished.
+func (p *pool[Input, Output]) resizer(finished <-chan struct{}) {
+	for {
+		// Get the channel before the limit
+		// so that no change is missed
+		changed := limitChanged(p.cfg.limit)
+		p.grow()
+		select {
+		case <-changed:
+		case <-finished:
+			return
+		}
+	}
+}
+
+// run calls task and sends its Result.
+// If the task calls runtime.Goexit,
+// run calls onGoexit and the Goroutine exits.
+func (p *pool[Input, Output]) run(inval Input, task Task[Input, Output], onGoexit func()) {
+	start := time.Now()
+	returned := false
+	defer func() {
+		if returned {
+			return
+		}
+		pval := recover()
+		if pval == nil {
+			// The task called runtime.Goexit
+			onGoexit()
+			p.send(Result[Input, Output]{
+				In:     inval,
+				Goexit: true,
+			})
+			return
+		}
+		pe := newPanicError(-1, inval, pval)
+		if r, ok := pval.(recovered); ok {
+			pe = r.pe
+		}
+		p.observe(start, pe)
+		if p.cfg.onPanic != nil {
+			p.send(Result[Input, Output]{
+				In:  inval,
+				Err: p.cfg.onPanic(pe),
+			})
+			return
+		}
+		p.send(Result[Input, Output]{
+			In:    inval,
+			Panic: pe,
+		})
+	}()
+
+	outval, err := task(inval)
+	returned = true
+	p.observe(start, err)
+	p.send(Result[Input, Output]{In: inval, Out: outval, Err: err})
+}
+
+// observe reports the latency and error of a task to cfg.limit.
+func (p *pool[Input, Output]) observe(start time.Time, err error) {
+	if p.cfg.limit != nil {
+		p.cfg.limit.Observe(time.Since(start), err)
+	}
+}
+
+// intake moves inputs from inch into a bounded queue for the workers,
+// applying the overflow policy when the queue is full.
+func (p *pool[Input, Output]) intake(inch <-chan Input) {
+	defer p.wg.Done()
+	defer close(p.work)
+	queue := deque.Make[Input](p.cfg.queueSize)
+	for inch != nil || queue.Len() > 0 {
+		var (
+			work chan<- Input
+			head Input
+		)
+		if queue.Len() > 0 {
+			work = p.work
+			head, _ = queue.Head()
+		}
+		select {
+		case inval, ok := <-inch:
+			if !ok {
+				inch = nil
+				continue
+			}
+			if queue.Len() == 0 {
+				// Skip the queue if a worker is free
+				select {
+				case p.work <- inval:
+					continue
+				default:
+				}
+			}
+			if queue.Len() < p.cfg.queueSize {
+				queue.PushBack(inval)
+				continue
+			}
+			switch p.cfg.overflow {
+			case Reject:
+				p.send(Result[Input, Output]{In: inval, Err: ErrQueueFull})
+			case DropOldest:
+				if oldest, ok := queue.RemoveFront(); ok {
+					p.send(Result[Input, Output]{In: oldest, Err: ErrDropped})
+					queue.PushBack(inval)
+				} else {
+					p.send(Result[Input, Output]{In: inval, Err: ErrDropped})
+				}
+			case CallerRuns:
+				p.runInline(inval)
+			}
+		case work <- head:
+			queue.RemoveFront()
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// runInline runs a task for the intake Goroutine
+// and waits for it to finish.
+func (p *pool[Input, Output]) runInline(inval Input) {
+	finished := make(chan struct{})
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer close(finished)
+		task, stop := p.start()
+		defer stop()
+		p.run(inval, task, func() {})
+	}()
+	<-finished
+}
+
+// indexed is an input along with its position.
+type indexed[Input any] struct {
+	pos  int
+	item Input
+}
+
+// orderedTaskPool wraps a taskPool
+// so that Results are sent in the order inputs were received.
+// Inputs are not accepted while cfg.window Results are pending.
+func orderedTaskPool[Input, Output any](numWorkers int, start starter[Input, Output], cfg config, done <-chan struct{}) (in chan<- Input, out <-chan Result[Input, Output]) {
+	window := cfg.window
+	if window < 1 {
+		window = 2 * numWorkers
+	}
+	outSize := numWorkers
+	if cfg.outputBuffer > 0 {
+		outSize = cfg.outputBuffer
+	}
+	inch := make(chan Input)
+	ouch := make(chan Result[Input, Output], outSize)
+	// Apply the panic policy here, after the input is unwrapped
+	poolcfg := cfg
+	poolcfg.onPanic = nil
+	poolin, poolout := taskPool(numWorkers, func() (Task[indexed[Input], Output], func()) {
+		task, stop := start()
+		return func(in indexed[Input]) (Output, error) {
+			return task(in.item)
+		}, stop
+	}, poolcfg, done)
+	slots := make(chan struct{}, window)
+	go func() {
+		defer close(poolin)
+		for pos := 0; ; pos++ {
+			var inval Input
+			select {
+			case slots <- struct{}{}:
+			case <-done:
+				return
+			}
+			select {
+			case v, ok := <-inch:
+				if !ok {
+					return
+				}
+				inval = v
+			case <-done:
+				return
+			}
+			select {
+			case poolin <- indexed[Input]{pos, inval}:
+			case <-done:
+				return
+			}
+		}
+	}()
 	go func() {
-		wg.Wait()
-		close(ouch)
+		defer close(ouch)
+		pending := make(map[int]Result[indexed[Input], Output], window)
+		next := 0
+		for r := range poolout {
+			pending[r.In.pos] = r
+			for {
+				r, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				next++
+				if r.Panic != nil {
+					pe := r.Panic.(*PanicError)
+					pe.Input = r.In.item
+					if cfg.onPanic != nil {
+						r.Panic = nil
+						r.Err = cfg.onPanic(pe)
+					}
+				}
+				select {
+				case ouch <- Result[Input, Output]{
+					In:     r.In.item,
+					Out:    r.Out,
+					Err:    r.Err,
+					Panic:  r.Panic,
+					Goexit: r.Goexit,
+				}:
+				case <-done:
+				}
+				<-slots
+			}
+		}
 	}()
 	return inch, ouch
 }
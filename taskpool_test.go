@@ -0,0 +1,253 @@
+package flowmatic_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/flowmatic"
+)
+
+func TestTaskPool_Ordered(t *testing.T) {
+	const window = 4
+	var started atomic.Int64
+	block := make(chan struct{})
+	in, out := flowmatic.TaskPool(3, func(n int) (int, error) {
+		started.Add(1)
+		if n == 0 {
+			// Hold up the head of the line
+			<-block
+		}
+		time.Sleep(time.Duration(10-n) * time.Millisecond)
+		return n * 2, nil
+	}, flowmatic.Ordered(window))
+	go func() {
+		defer close(in)
+		for i := 0; i < 10; i++ {
+			in <- i
+		}
+	}()
+	time.Sleep(50 * time.Millisecond)
+	// Only window inputs should have been accepted
+	// while the first one is blocked
+	if n := started.Load(); n != window {
+		t.Fatal(n)
+	}
+	close(block)
+	var got []int
+	for r := range out {
+		got = append(got, r.Out)
+	}
+	if fmt.Sprint(got) != "[0 2 4 6 8 10 12 14 16 18]" {
+		t.Fatal(got)
+	}
+}
+
+func TestTaskPool_Ordered_panic(t *testing.T) {
+	in, out := flowmatic.TaskPool(2, func(s string) (string, error) {
+		if s == "b" {
+			panic(s)
+		}
+		return s, nil
+	}, flowmatic.Ordered(0), flowmatic.PanicsAsErrors())
+	go func() {
+		defer close(in)
+		for _, s := range []string{"a", "b", "c"} {
+			in <- s
+		}
+	}()
+	var got []string
+	for r := range out {
+		var pe *flowmatic.PanicError
+		if r.Err != nil && (!errors.As(r.Err, &pe) || pe.Input != "b") {
+			t.Fatal(r.Err)
+		}
+		got = append(got, fmt.Sprintf("%s %t", r.In, r.Err != nil))
+	}
+	if fmt.Sprint(got) != "[a false b true c false]" {
+		t.Fatal(got)
+	}
+}
+
+func TestTaskPoolContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var started atomic.Int64
+	in, out := flowmatic.TaskPoolContext(ctx, 2, func(ctx context.Context, n int) (int, error) {
+		started.Add(1)
+		<-ctx.Done()
+		return n, ctx.Err()
+	})
+	in <- 1
+	in <- 2
+	cancel()
+	// Nobody reads the results or closes in,
+	// but the pool shuts down anyway
+	time.Sleep(10 * time.Millisecond)
+	for range out {
+	}
+	if n := started.Load(); n != 2 {
+		t.Fatal(n)
+	}
+	// Sends after cancellation are not consumed
+	select {
+	case in <- 3:
+		t.Fatal("should not accept input")
+	default:
+	}
+}
+
+func TestTaskPool_overflow(t *testing.T) {
+	cases := []struct {
+		policy flowmatic.Overflow
+		want   string
+	}{
+		{flowmatic.Reject, "[1 2 3:flowmatic: task queue is full]"},
+		{flowmatic.DropOldest, "[1 2:flowmatic: task dropped from queue 3]"},
+		{flowmatic.CallerRuns, "[1 2 3]"},
+	}
+	for _, tc := range cases {
+		t.Run(fmt.Sprint(tc.policy), func(t *testing.T) {
+			block := make(chan struct{})
+			in, out := flowmatic.TaskPool(1, func(n int) (int, error) {
+				if n == 1 {
+					<-block
+				}
+				return n, nil
+			}, flowmatic.QueueSize(1), flowmatic.OnOverflow(tc.policy), flowmatic.OutputBuffer(3))
+			in <- 1
+			time.Sleep(10 * time.Millisecond)
+			// With the worker busy, 2 waits in the queue,
+			// so 3 overflows
+			in <- 2
+			in <- 3
+			if tc.policy != flowmatic.CallerRuns {
+				close(block)
+			} else {
+				// 3 ran even though the worker is busy
+				r := <-out
+				if r.In != 3 || r.Err != nil {
+					t.Fatal(r)
+				}
+				close(block)
+			}
+			close(in)
+			var got []string
+			for r := range out {
+				if r.Err != nil {
+					got = append(got, fmt.Sprintf("%d:%v", r.In, r.Err))
+				} else {
+					got = append(got, fmt.Sprint(r.In))
+				}
+			}
+			if tc.policy == flowmatic.CallerRuns {
+				got = append(got, "3")
+			}
+			slices.Sort(got)
+			if s := fmt.Sprint(got); s != tc.want {
+				t.Fatal(s)
+			}
+		})
+	}
+}
+
+func TestTaskPool_QueueSize(t *testing.T) {
+	block := make(chan struct{})
+	in, out := flowmatic.TaskPool(1, func(n int) (int, error) {
+		<-block
+		return n, nil
+	}, flowmatic.QueueSize(3))
+	// Sends don't block while there is room in the queue
+	for i := 0; i < 3; i++ {
+		in <- i
+	}
+	close(block)
+	close(in)
+	n := 0
+	for range out {
+		n++
+	}
+	if n != 3 {
+		t.Fatal(n)
+	}
+}
+
+func TestTaskPool_WorkerLimit(t *testing.T) {
+	dl := flowmatic.NewDynamicLimit(4)
+	var lt limitTracker
+	release := make(chan struct{})
+	wait5 := barrier(5)
+	in, out := flowmatic.TaskPool(0, func(n int) (int, error) {
+		lt.start()
+		defer lt.done()
+		switch {
+		case n < 4:
+			<-release
+		case n >= 100 && n < 105:
+			// Hangs unless the pool grows to 5 workers
+			wait5()
+		default:
+			time.Sleep(time.Millisecond)
+		}
+		return n, nil
+	}, flowmatic.WorkerLimit(dl))
+	go func() {
+		for range out {
+		}
+	}()
+
+	// Fill the pool, then shrink it while every worker is busy
+	for i := 0; i < 4; i++ {
+		in <- i
+	}
+	dl.Resize(2)
+	close(release)
+	for lt.cur.Load() != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	lt.max.Store(0)
+	for i := 4; i < 30; i++ {
+		in <- i
+	}
+	for lt.cur.Load() != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if p := lt.max.Load(); p > 2 {
+		t.Fatalf("ran %d tasks at once with a limit of 2", p)
+	}
+
+	// Grow the pool
+	lt.max.Store(0)
+	dl.Resize(5)
+	for i := 100; i < 130; i++ {
+		in <- i
+	}
+	close(in)
+	for lt.cur.Load() != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if p := lt.max.Load(); p != 5 {
+		t.Fatalf("ran %d tasks at once with a limit of 5", p)
+	}
+	if dl.Limit() != 5 {
+		t.Fatal(dl.Limit())
+	}
+}
+
+func TestTaskPool_OutputBuffer(t *testing.T) {
+	for _, opts := range [][]flowmatic.Option{
+		{flowmatic.OutputBuffer(50)},
+		{flowmatic.Ordered(0), flowmatic.OutputBuffer(50)},
+	} {
+		in, out := flowmatic.TaskPool(2, func(n int) (int, error) {
+			return n, nil
+		}, opts...)
+		close(in)
+		if cap(out) != 50 {
+			t.Fatal(cap(out))
+		}
+	}
+}